@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+
+	"hw1_tree/tree"
+)
+
+type colorMode int
+
+const (
+	colorAuto colorMode = iota
+	colorAlways
+	colorNever
+)
+
+var colorModeNames = map[colorMode]string{
+	colorAuto:   "auto",
+	colorAlways: "always",
+	colorNever:  "never",
+}
+
+func parseColorMode(name string) (colorMode, error) {
+	for m, n := range colorModeNames {
+		if n == strings.ToLower(name) {
+			return m, nil
+		}
+	}
+	return colorAuto, fmt.Errorf("unknown color mode %q", name)
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type config struct {
+	opts     tree.Options
+	roots    []string
+	diff     string
+	noReport bool
+	watch    bool
+	manifest string
+	fixture  string
+	check    string
+	watchOpt tree.WatchOptions
+}
+
+func parseArgs(args []string, stdout, stderr io.Writer) (*config, error) {
+	cfg := &config{}
+	var format, style, sortOrder, units, timeFormat, color string
+	var find, findRegex string
+	var asJSON, dirsOnly bool
+
+	fs := flag.NewFlagSet("tree", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: tree [flags] [path ...]")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&cfg.opts.PrintFiles, "f", false, "print files")
+	fs.BoolVar(&cfg.opts.ShowHidden, "a", false, "print hidden files and directories")
+	fs.BoolVar(&dirsOnly, "d", false, "print directories only, with the number of files in each")
+	fs.IntVar(&cfg.opts.MaxDepth, "L", 0, "max display depth, 0 - unlimited")
+	fs.StringVar(&format, "format", "text", "output format: text, json, yaml, ndjson")
+	fs.BoolVar(&asJSON, "json", false, "same as -format=json")
+	fs.StringVar(&style, "style", "classic", "text style: classic, ascii, unicode, markdown, html")
+	fs.Var((*stringList)(&cfg.opts.Include), "include", "list only files matching the glob, can be repeated")
+	fs.Var((*stringList)(&cfg.opts.Exclude), "exclude", "skip entries matching the glob, can be repeated")
+	fs.BoolVar(&cfg.opts.GitIgnore, "gitignore", false, "honour .gitignore files")
+	fs.StringVar(&sortOrder, "sort", "name", "sort order: name, size, mtime, ext")
+	fs.BoolVar(&cfg.opts.DirsFirst, "dirsfirst", false, "list directories before files")
+	fs.BoolVar(&cfg.opts.Reverse, "r", false, "reverse sort order")
+	fs.BoolVar(&cfg.opts.DirSizes, "du", false, "print total size of directories")
+	fs.BoolVar(&cfg.opts.FileCounts, "counts", false, "print number of files in directories")
+	fs.StringVar(&units, "units", "bytes", "size units: bytes, iec, si")
+	fs.BoolVar(&cfg.opts.FollowSymlinks, "l", false, "follow symbolic links to directories")
+	fs.IntVar(&cfg.opts.Workers, "workers", 0, "read directories with this many workers")
+	fs.BoolVar(&cfg.opts.Stream, "stream", false, "print entries while walking, without building the tree")
+	fs.BoolVar(&cfg.opts.Strict, "strict", false, "stop on the first error")
+	fs.StringVar(&find, "find", "", "show only entries whose name matches the glob and the directories leading to them")
+	fs.StringVar(&findRegex, "regex", "", "same as -find, but with a regular expression")
+	fs.BoolVar(&cfg.opts.Highlight, "highlight", false, "highlight matches of -find or -regex")
+	fs.StringVar(&color, "color", "auto", "colorize names using LS_COLORS: auto, always, never")
+	fs.BoolVar(&cfg.opts.Perms, "p", false, "print permissions")
+	fs.BoolVar(&cfg.opts.Owner, "u", false, "print file owner")
+	fs.BoolVar(&cfg.opts.Group, "g", false, "print file group")
+	fs.BoolVar(&cfg.opts.ModTimes, "D", false, "print modification time")
+	fs.StringVar(&timeFormat, "timefmt", "default", "time format for -D: default, iso, full, rfc3339 or a Go layout")
+	fs.BoolVar(&cfg.opts.Hash, "hash", false, "print sha256 of files, needs -f")
+	fs.BoolVar(&cfg.opts.Duplicates, "dups", false, "report files with identical content, needs -f")
+	fs.StringVar(&cfg.diff, "diff", "", "compare with this directory or JSON snapshot")
+	fs.BoolVar(&cfg.noReport, "noreport", false, "omit the directories and files summary")
+	fs.StringVar(&cfg.manifest, "manifest", "", "write a manifest of the tree to this file, - for stdout")
+	fs.Int64Var(&cfg.opts.InlineSize, "inline", tree.ManifestInline, "with -manifest, store content of files up to this size, larger files get a sha256; -1 - none")
+	fs.StringVar(&cfg.fixture, "materialize", "", "create the tree described by this manifest at path")
+	fs.StringVar(&cfg.check, "check", "", "compare path with this manifest, including file content")
+	fs.BoolVar(&cfg.watch, "watch", false, "keep running and redraw the tree when it changes")
+	fs.BoolVar(&cfg.watchOpt.Events, "events", false, "in watch mode print changes instead of the tree")
+	fs.DurationVar(&cfg.watchOpt.Poll, "poll", 0, "in watch mode rescan with this interval instead of inotify")
+
+	// флаги и пути можно перемешивать: tree . -f
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cfg.roots = append(cfg.roots, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(cfg.roots) == 0 {
+		cfg.roots = []string{"."}
+	}
+
+	// без -f файлов в дереве нет, хешировать и сравнивать нечего
+	if (cfg.opts.Hash || cfg.opts.Duplicates) && !cfg.opts.PrintFiles {
+		return nil, fmt.Errorf("-hash and -dups need -f")
+	}
+	if dirsOnly {
+		if cfg.opts.PrintFiles {
+			return nil, fmt.Errorf("-d and -f can't be used together")
+		}
+		cfg.opts.FileCounts = true
+	}
+
+	var err error
+	if asJSON {
+		format = "json"
+	}
+	if cfg.opts.Format, err = tree.ParseFormat(format); err != nil {
+		return nil, err
+	}
+	if cfg.opts.Style, err = tree.ParseStyle(style); err != nil {
+		return nil, err
+	}
+	if cfg.opts.Sort, err = tree.ParseSortOrder(sortOrder); err != nil {
+		return nil, err
+	}
+	if cfg.opts.Units, err = tree.ParseUnits(units); err != nil {
+		return nil, err
+	}
+	cfg.opts.TimeFormat = tree.ParseTimeFormat(timeFormat)
+	mode, err := parseColorMode(color)
+	if err != nil {
+		return nil, err
+	}
+	if useColor(mode, stdout) && cfg.opts.Format == tree.FormatText && cfg.opts.Style.IsTree() {
+		cfg.opts.Colors = tree.ParseLSColors(os.Getenv("LS_COLORS"))
+	}
+	switch {
+	case find != "" && findRegex != "":
+		return nil, fmt.Errorf("-find and -regex can't be used together")
+	case find != "":
+		cfg.opts.Find, err = tree.GlobRegexp(find)
+	case findRegex != "":
+		cfg.opts.Find, err = regexp.Compile(findRegex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.opts.Stream {
+		if err := tree.CheckStream(cfg.opts); err != nil {
+			return nil, fmt.Errorf("-stream: %v", err)
+		}
+	}
+	return cfg, tree.CheckPatterns(cfg.opts.Include, cfg.opts.Exclude)
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseArgs(args, stdout, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "tree:", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if cfg.diff != "" {
+		return runDiff(ctx, cfg, stdout, stderr)
+	}
+	if cfg.watch {
+		return runWatch(ctx, cfg, stdout, stderr)
+	}
+	if cfg.manifest != "" || cfg.fixture != "" || cfg.check != "" {
+		return runManifest(ctx, cfg, stdout, stderr)
+	}
+
+	code := 0
+	total := tree.Stats{}
+	printRoots(stdout, cfg.opts.Format, cfg.roots, func(out io.Writer, root string) {
+		stats, err := tree.Print(ctx, out, root, cfg.opts)
+		total.Dirs += stats.Dirs
+		total.Files += stats.Files
+		if err != nil {
+			printError(stderr, err)
+			code = 1
+		}
+	})
+	if !cfg.noReport && cfg.opts.Format == tree.FormatText {
+		fmt.Fprintf(stdout, "\n%s\n", total.Summary(cfg.opts.PrintFiles))
+	}
+	return code
+}
+
+func runDiff(ctx context.Context, cfg *config, stdout, stderr io.Writer) int {
+	code := 0
+	total := tree.DiffStats{}
+	printRoots(stdout, cfg.opts.Format, cfg.roots, func(out io.Writer, root string) {
+		stats, err := tree.Diff(ctx, out, cfg.diff, root, cfg.opts)
+		total.Added += stats.Added
+		total.Removed += stats.Removed
+		total.Changed += stats.Changed
+		if err != nil {
+			printError(stderr, err)
+			code = 1
+		}
+	})
+	if !cfg.noReport && cfg.opts.Format == tree.FormatText {
+		fmt.Fprintf(stdout, "\n%s\n", total)
+	}
+	return code
+}
+
+// printRoots выводит корни по очереди так, чтобы вывод остался одним документом:
+// в text каждый корень подписан путём, в yaml документы разделены ---, json собирается в массив
+func printRoots(out io.Writer, format tree.Format, roots []string, print func(out io.Writer, root string)) {
+	if len(roots) == 1 {
+		print(out, roots[0])
+		return
+	}
+	if format == tree.FormatJSON {
+		fmt.Fprint(out, "[\n")
+	}
+	written := 0
+	for _, root := range roots {
+		switch format {
+		case tree.FormatText:
+			fmt.Fprintln(out, root)
+			print(out, root)
+			continue
+		case tree.FormatNDJSON:
+			print(out, root)
+			continue
+		}
+		// корень с ошибкой может ничего не вывести, разделитель ставим только между документами
+		buf := new(bytes.Buffer)
+		print(buf, root)
+		if buf.Len() == 0 {
+			continue
+		}
+		if written > 0 {
+			if format == tree.FormatJSON {
+				fmt.Fprint(out, ",\n")
+			} else {
+				fmt.Fprint(out, "---\n")
+			}
+		}
+		written++
+		out.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		fmt.Fprintln(out)
+	}
+	if format == tree.FormatJSON {
+		fmt.Fprint(out, "]\n")
+	}
+}
+
+func runWatch(ctx context.Context, cfg *config, stdout, stderr io.Writer) int {
+	if len(cfg.roots) > 1 {
+		fmt.Fprintln(stderr, "tree: -watch takes a single path")
+		return 2
+	}
+	if cfg.opts.Stream {
+		fmt.Fprintln(stderr, "tree: -watch can't be combined with -stream")
+		return 2
+	}
+	opts := cfg.watchOpt
+	opts.Clear = !opts.Events && isTerminal(stdout)
+	if err := tree.Watch(ctx, stdout, cfg.roots[0], cfg.opts, opts); err != nil {
+		printError(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runManifest(ctx context.Context, cfg *config, stdout, stderr io.Writer) int {
+	modes := 0
+	for _, flag := range []string{cfg.manifest, cfg.fixture, cfg.check} {
+		if flag != "" {
+			modes++
+		}
+	}
+	if len(cfg.roots) > 1 || modes > 1 {
+		fmt.Fprintln(stderr, "tree: -manifest, -materialize and -check take a single path and can't be combined")
+		return 2
+	}
+	var err error
+	switch {
+	case cfg.check != "":
+		return checkManifest(ctx, cfg, stdout, stderr)
+	case cfg.fixture != "":
+		err = materialize(cfg.fixture, cfg.roots[0])
+	default:
+		err = writeManifest(ctx, cfg, stdout)
+	}
+	if err != nil {
+		printError(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// checkManifest выводит расхождения каталога с манифестом, код 1 - если они есть
+func checkManifest(ctx context.Context, cfg *config, stdout, stderr io.Writer) int {
+	f, err := os.Open(cfg.check)
+	if err != nil {
+		printError(stderr, err)
+		return 1
+	}
+	defer f.Close()
+	stats, err := tree.CheckManifest(ctx, stdout, f, cfg.roots[0], cfg.opts)
+	if err != nil {
+		printError(stderr, err)
+		return 1
+	}
+	if !cfg.noReport && cfg.opts.Format == tree.FormatText {
+		fmt.Fprintf(stdout, "\n%s\n", stats)
+	}
+	if stats != (tree.DiffStats{}) {
+		return 1
+	}
+	return 0
+}
+
+func materialize(manifest, dir string) error {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return tree.Materialize(f, dir)
+}
+
+func writeManifest(ctx context.Context, cfg *config, stdout io.Writer) error {
+	if cfg.manifest == "-" {
+		return tree.WriteManifest(ctx, stdout, cfg.roots[0], cfg.opts)
+	}
+	f, err := os.Create(cfg.manifest)
+	if err != nil {
+		return err
+	}
+	if err := tree.WriteManifest(ctx, f, cfg.roots[0], cfg.opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func useColor(mode colorMode, out io.Writer) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(out)
+}
+
+// isTerminal - вывод идёт в терминал, а не в файл или пайп
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func printError(stderr io.Writer, err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintln(stderr, "tree:", line)
+	}
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -73,3 +76,187 @@ func TestTreeDir(t *testing.T) {
 		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
 	}
 }
+
+const testCLIResult = `testdata/project
+├───file.txt (19b)
+└───gopher.png (70372b)
+testdata/zline
+├───empty.txt (empty)
+└───lorem
+
+1 directory, 3 files
+`
+
+func TestRun(t *testing.T) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run([]string{"testdata/project", "-f", "-L", "1", "testdata/zline"}, stdout, stderr)
+	if code != 0 || stderr.Len() != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	if result := stdout.String(); result != testCLIResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testCLIResult)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-noreport", "testdata/missing"}, stdout, stderr); code != 1 {
+		t.Errorf("expected exit code 1 for missing root, got %d", code)
+	}
+	if code := run([]string{"-sort", "color", "testdata"}, stdout, stderr); code != 2 {
+		t.Errorf("expected exit code 2 for bad flag, got %d", code)
+	}
+	if code := run([]string{"-dups", "testdata"}, stdout, stderr); code != 2 {
+		t.Errorf("expected exit code 2 for -dups without -f, got %d", code)
+	}
+	if stdout.Len() != 0 || !strings.Contains(stderr.String(), "testdata/missing") {
+		t.Errorf("unexpected output\nstdout:\n%v\nstderr:\n%v", stdout, stderr)
+	}
+}
+
+const testDirsOnlyResult = `├───a_lorem (3 files)
+│	└───ipsum (1 file)
+├───css (1 file)
+├───html (1 file)
+├───js (1 file)
+└───z_lorem (3 files)
+	└───ipsum (1 file)
+
+7 directories
+`
+
+const testDirsOnlyDepthResult = `├───a_lorem (3 files)
+├───css (1 file)
+├───html (1 file)
+├───js (1 file)
+└───z_lorem (3 files)
+
+5 directories
+`
+
+func TestRunDirsOnly(t *testing.T) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-d", "testdata/static"}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	if result := stdout.String(); result != testDirsOnlyResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testDirsOnlyResult)
+	}
+
+	// на границе -L каталоги показывают все файлы под ними, а не только прочитанные
+	stdout.Reset()
+	if code := run([]string{"-d", "-L", "1", "testdata/static"}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	if result := stdout.String(); result != testDirsOnlyDepthResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testDirsOnlyDepthResult)
+	}
+
+	if code := run([]string{"-d", "-f", "testdata"}, stdout, stderr); code != 2 {
+		t.Errorf("expected exit code 2 for -d with -f, got %d", code)
+	}
+}
+
+func TestRunRoots(t *testing.T) {
+	// несколько корней в json - один массив, корень с ошибкой в него не попадает
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run([]string{"-format", "json", "testdata/project", "testdata/missing", "testdata/zline"}, stdout, stderr)
+	if code != 1 {
+		t.Errorf("expected exit code 1 for missing root, got %d", code)
+	}
+	roots := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(stdout.Bytes(), &roots); err != nil {
+		t.Fatalf("bad json: %v\n%v", err, stdout)
+	}
+	if len(roots) != 2 || roots[0].Name != "testdata/project" || roots[1].Name != "testdata/zline" {
+		t.Errorf("results not match\nGot: %+v\nExpected: testdata/project, testdata/zline", roots)
+	}
+
+	// в yaml каждый корень - отдельный документ
+	stdout.Reset()
+	if code := run([]string{"-format", "yaml", "testdata/project", "testdata/zline"}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	docs := strings.Split(stdout.String(), "---\n")
+	if len(docs) != 2 || !strings.HasPrefix(docs[0], `name: "testdata/project"`) || !strings.HasPrefix(docs[1], `name: "testdata/zline"`) {
+		t.Errorf("expected two yaml documents, got:\n%v", stdout)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	// то, что нельзя вывести потоком, отклоняется при разборе флагов, до обхода и без итогов
+	cases := [][]string{
+		{"-stream", "-du", "testdata"},
+		{"-stream", "-counts", "testdata"},
+		{"-stream", "-d", "testdata"},
+		{"-stream", "-f", "-dups", "testdata"},
+		{"-stream", "-find", "*.txt", "testdata"},
+		{"-stream", "-regex", "txt$", "testdata"},
+		{"-stream", "-style", "markdown", "testdata"},
+		{"-stream", "-format", "yaml", "testdata"},
+	}
+	for _, args := range cases {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		if code := run(args, stdout, stderr); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+		if stdout.Len() != 0 || !strings.Contains(stderr.String(), "-stream") {
+			t.Errorf("%v: unexpected output\nstdout:\n%v\nstderr:\n%v", args, stdout, stderr)
+		}
+	}
+}
+
+func TestRunColor(t *testing.T) {
+	out := new(bytes.Buffer)
+	if code := run([]string{"-color=always", "testdata/static"}, out, out); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, out)
+	}
+	if !strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("expected colored output, got:\n%v", out)
+	}
+
+	out.Reset()
+	if code := run([]string{"-color=auto", "testdata/static"}, out, out); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, out)
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("colors must be disabled when output is not a terminal:\n%v", out)
+	}
+}
+
+func TestRunManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "project.manifest")
+	fixture := filepath.Join(dir, "project")
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-manifest", manifest, "testdata/project"}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	if code := run([]string{"-materialize", manifest, fixture}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	// gopher.png больше порога -inline, в фикстуре он из нулей, и -check это видит
+	if code := run([]string{"-noreport", "-check", manifest, fixture}, stdout, stderr); code != 1 {
+		t.Fatalf("expected exit code 1 for zero-filled file, got %d: %v", code, stderr)
+	}
+	expected := "├───file.txt (19b)\n└───[~] gopher.png (70372b -> 70372b)\n"
+	if result := stdout.String(); result != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	stdout.Reset()
+	fixture = filepath.Join(dir, "inline")
+	if code := run([]string{"-inline", "100000", "-manifest", manifest, "testdata/project"}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	if code := run([]string{"-materialize", manifest, fixture}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v", code, stderr)
+	}
+	if code := run([]string{"-noreport", "-check", manifest, fixture}, stdout, stderr); code != 0 {
+		t.Fatalf("unexpected exit code %d: %v\n%v", code, stderr, stdout)
+	}
+	expected = "├───file.txt (19b)\n└───gopher.png (70372b)\n"
+	if result := stdout.String(); result != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
@@ -0,0 +1,97 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+const colorReset = "\x1b[0m"
+
+// defaultLSColors - то же, что dircolors выдаёт для основных типов без настроек
+const defaultLSColors = "di=01;34:ln=01;36:or=40;31;01:pi=40;33:so=01;35:bd=40;33;01:cd=40;33;01:ex=01;32"
+
+// ColorScheme - разобранная переменная LS_COLORS: коды типов (di, ln, ex...) и расширений (*.tar)
+type ColorScheme struct {
+	types map[string]string
+	exts  map[string]string
+}
+
+// ParseLSColors накладывает lsColors поверх стандартных цветов
+func ParseLSColors(lsColors string) *ColorScheme {
+	s := &ColorScheme{types: map[string]string{}, exts: map[string]string{}}
+	for _, spec := range []string{defaultLSColors, lsColors} {
+		for _, item := range strings.Split(spec, ":") {
+			key, code, ok := strings.Cut(item, "=")
+			if !ok || key == "" {
+				continue
+			}
+			if !validColorCode(key, code) {
+				continue
+			}
+			if strings.HasPrefix(key, "*") {
+				s.exts[key[1:]] = code
+			} else {
+				s.types[key] = code
+			}
+		}
+	}
+	return s
+}
+
+// validColorCode - код из цифр и ';' (SGR), для ln ещё target
+func validColorCode(key, code string) bool {
+	if key == "ln" && code == "target" {
+		return true
+	}
+	for _, c := range code {
+		if (c < '0' || c > '9') && c != ';' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ColorScheme) code(node *Node) string {
+	mode, isDir := node.Mode, node.IsDir
+	// ln=target - симлинк красится как файл, на который он ведёт
+	linkAsTarget := s.types["ln"] == "target"
+	if node.Link != "" && linkAsTarget && mode&fs.ModeSymlink != 0 {
+		mode, isDir = node.targetMode, node.targetMode.IsDir()
+	}
+	switch {
+	case errors.Is(node.Err, errBrokenSymlink):
+		return s.types["or"]
+	case node.Link != "" && !linkAsTarget:
+		return s.types["ln"]
+	case isDir:
+		return s.types["di"]
+	case mode&fs.ModeNamedPipe != 0:
+		return s.types["pi"]
+	case mode&fs.ModeSocket != 0:
+		return s.types["so"]
+	case mode&fs.ModeCharDevice != 0:
+		return s.types["cd"]
+	case mode&fs.ModeDevice != 0:
+		return s.types["bd"]
+	case mode&0111 != 0:
+		return s.types["ex"]
+	}
+	code, longest := s.types["fi"], 0
+	for ext, extCode := range s.exts {
+		if len(ext) > longest && strings.HasSuffix(node.Name, ext) {
+			code, longest = extCode, len(ext)
+		}
+	}
+	return code
+}
+
+// colorize красит имя; внутри могут быть подсветки -highlight, после них цвет восстанавливаем
+func (s *ColorScheme) colorize(name string, node *Node) string {
+	code := s.code(node)
+	if code == "" || strings.Trim(code, "0") == "" {
+		return name
+	}
+	start := "\x1b[" + code + "m"
+	return start + strings.ReplaceAll(name, highlightEnd, highlightEnd+start) + colorReset
+}
@@ -0,0 +1,178 @@
+package tree
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type DiffStatus int
+
+const (
+	DiffNone DiffStatus = iota
+	DiffAdded
+	DiffRemoved
+	DiffChanged
+)
+
+var diffMarks = map[DiffStatus]string{
+	DiffAdded:   "[+] ",
+	DiffRemoved: "[-] ",
+	DiffChanged: "[~] ",
+}
+
+var diffNames = map[DiffStatus]string{
+	DiffAdded:   "added",
+	DiffRemoved: "removed",
+	DiffChanged: "changed",
+}
+
+type DiffStats struct {
+	Added   int
+	Removed int
+	Changed int
+}
+
+func (s DiffStats) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", s.Added, s.Removed, s.Changed)
+}
+
+// Diff выводит объединённое дерево oldPath и newPath, любой из них может быть
+// сохранённым через -json снимком
+func Diff(ctx context.Context, out io.Writer, oldPath, newPath string, opts Options) (DiffStats, error) {
+	stats := DiffStats{}
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return stats, err
+	}
+	w := newWalker(ctx, nil, opts)
+	oldRoot, err := w.loadRoot(oldPath)
+	if err != nil {
+		return stats, err
+	}
+	newRoot, err := w.loadRoot(newPath)
+	if err != nil {
+		return stats, err
+	}
+	if !oldRoot.IsDir || !newRoot.IsDir {
+		return stats, fmt.Errorf("can't diff %s and %s: both must be directories", oldPath, newPath)
+	}
+	return w.diff(out, oldRoot, newRoot)
+}
+
+func (w *walker) diff(out io.Writer, oldRoot, newRoot *Node) (DiffStats, error) {
+	stats := DiffStats{}
+	root := diffDir(oldRoot, newRoot, &w.opts, &stats)
+	renderer, err := newRenderer(&w.opts)
+	if err != nil {
+		return stats, err
+	}
+	if err := renderer.Render(out, root); err != nil {
+		return stats, err
+	}
+	return stats, w.err()
+}
+
+func (w *walker) loadRoot(path string) (*Node, error) {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() && strings.HasSuffix(path, ".json") {
+		return readSnapshot(path)
+	}
+	fsys, closeFS, err := openFS(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFS()
+	w.fsys = fsys
+	return w.buildRoot(path)
+}
+
+func readSnapshot(path string) (*Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	node := &fileJSON{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, fmt.Errorf("bad snapshot %s: %v", path, err)
+	}
+	return fromJSON(node), nil
+}
+
+func fromJSON(node *fileJSON) *Node {
+	f := &Node{
+		Name:      node.Name,
+		Size:      node.Size,
+		IsDir:     node.IsDir,
+		ModTime:   node.ModTime,
+		Link:      node.Link,
+		Cycle:     node.Cycle,
+		TotalSize: node.Total,
+		Files:     node.Files,
+		Hash:      node.Hash,
+	}
+	if node.Error != "" {
+		f.Err = errors.New(node.Error)
+	}
+	if f.IsDir {
+		f.Mode = os.ModeDir
+		f.Children = []*Node{}
+	}
+	if node.Children != nil {
+		for _, child := range *node.Children {
+			f.Children = append(f.Children, fromJSON(child))
+		}
+	}
+	return f
+}
+
+func diffDir(oldDir, newDir *Node, opts *Options, stats *DiffStats) *Node {
+	merged := *newDir
+	merged.Children = []*Node{}
+
+	olds := map[string]*Node{}
+	for _, child := range oldDir.Children {
+		olds[child.Name] = child
+	}
+	for _, child := range newDir.Children {
+		old, ok := olds[child.Name]
+		delete(olds, child.Name)
+		switch {
+		case !ok:
+			markTree(child, DiffAdded, stats)
+		case old.IsDir && child.IsDir:
+			child = diffDir(old, child, opts, stats)
+		case old.IsDir != child.IsDir:
+			markTree(old, DiffRemoved, stats)
+			merged.Children = append(merged.Children, old)
+			markTree(child, DiffAdded, stats)
+		case old.Size != child.Size || (old.Hash != "" && child.Hash != "" && old.Hash != child.Hash):
+			child.Diff, child.OldSize = DiffChanged, old.Size
+			stats.Changed++
+		}
+		merged.Children = append(merged.Children, child)
+	}
+	for _, old := range olds {
+		markTree(old, DiffRemoved, stats)
+		merged.Children = append(merged.Children, old)
+	}
+	sortFiles(merged.Children, opts)
+	return &merged
+}
+
+func markTree(f *Node, status DiffStatus, stats *DiffStats) {
+	f.Diff = status
+	if status == DiffAdded {
+		stats.Added++
+	} else {
+		stats.Removed++
+	}
+	if f.Children != nil {
+		for _, child := range f.Children {
+			markTree(child, status, stats)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+)
+
+var (
+	errVanished      = errors.New("vanished")
+	errBrokenSymlink = errors.New("broken symlink")
+)
+
+// fail помечает узел ошибкой и запоминает её; в строгом режиме ошибка возвращается сразу
+func (w *walker) fail(node *Node, err error) error {
+	node.Err = err
+	w.mu.Lock()
+	w.errs = append(w.errs, err)
+	w.mu.Unlock()
+	if w.opts.Strict {
+		return err
+	}
+	return nil
+}
+
+func (w *walker) err() error {
+	return errors.Join(w.errs...)
+}
+
+func errLabel(err error) string {
+	pathErr := &fs.PathError{}
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
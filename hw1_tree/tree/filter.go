@@ -0,0 +1,177 @@
+package tree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+const gitIgnoreFile = ".gitignore"
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreRules - правила одного .gitignore, base - его каталог относительно корня обхода
+type ignoreRules struct {
+	base   string
+	rules  []ignoreRule
+	parent *ignoreRules
+}
+
+func joinRel(rel, name string) string {
+	if rel == "" {
+		return name
+	}
+	return rel + "/" + name
+}
+
+func CheckPatterns(patterns ...[]string) error {
+	for _, list := range patterns {
+		for _, pattern := range list {
+			if _, err := GlobRegexp(pattern); err != nil {
+				return fmt.Errorf("bad pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *walker) skip(rel string, isDir bool, ignores *ignoreRules) bool {
+	name := path.Base(rel)
+	if !w.opts.ShowHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	if w.opts.GitIgnore && isDir && name == ".git" {
+		return true
+	}
+	for _, pattern := range w.opts.Exclude {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	if !isDir && len(w.opts.Include) > 0 {
+		included := false
+		for _, pattern := range w.opts.Include {
+			if matchGlob(pattern, rel) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+	return ignores.ignored(rel, isDir)
+}
+
+// matchGlob сравнивает шаблон без "/" с именем файла, а с "/" - с путём целиком
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		return matchName(pattern, path.Base(rel))
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if !matchName(pattern[0], parts[0]) {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func loadIgnoreRules(fsys fs.FS, dir, rel string, parent *ignoreRules) (*ignoreRules, error) {
+	f, err := fsys.Open(path.Join(dir, gitIgnoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return parent, nil
+	}
+	if err != nil {
+		return parent, err
+	}
+	defer f.Close()
+
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return parent, err
+	}
+	if len(rules) == 0 {
+		return parent, nil
+	}
+	return &ignoreRules{base: rel, rules: rules, parent: parent}, nil
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	rule.anchored = strings.Contains(line, "/")
+	rule.pattern = strings.TrimPrefix(line, "/")
+	return rule, rule.pattern != ""
+}
+
+// ignored - побеждает последнее совпавшее правило, правила вложенных .gitignore важнее внешних
+func (l *ignoreRules) ignored(rel string, isDir bool) bool {
+	if l == nil {
+		return false
+	}
+	ignored := l.parent.ignored(rel, isDir)
+
+	local := rel
+	if l.base != "" {
+		local = strings.TrimPrefix(rel, l.base+"/")
+	}
+	for _, rule := range l.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if rule.anchored {
+			ok = matchSegments(strings.Split(rule.pattern, "/"), strings.Split(local, "/"))
+		} else {
+			ok = matchName(rule.pattern, path.Base(local))
+		}
+		if ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,189 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+	FormatYAML
+	FormatNDJSON
+)
+
+var formatNames = map[Format]string{
+	FormatText:   "text",
+	FormatJSON:   "json",
+	FormatYAML:   "yaml",
+	FormatNDJSON: "ndjson",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+func ParseFormat(name string) (Format, error) {
+	for f, n := range formatNames {
+		if n == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	return FormatText, fmt.Errorf("unknown format %q", name)
+}
+
+// fileJSON - представление Node для сериализации, mode пишем строкой как в ls
+type fileJSON struct {
+	Path     string       `json:"path,omitempty"`
+	Name     string       `json:"name"`
+	Size     int64        `json:"size"`
+	IsDir    bool         `json:"is_dir"`
+	Mode     string       `json:"mode"`
+	ModTime  time.Time    `json:"mtime"`
+	Link     string       `json:"link,omitempty"`
+	Cycle    bool         `json:"cycle,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Total    int64        `json:"total_size,omitempty"`
+	Files    int          `json:"files,omitempty"`
+	Diff     string       `json:"diff,omitempty"`
+	OldSize  int64        `json:"old_size,omitempty"`
+	Hash     string       `json:"sha256,omitempty"`
+	Children *[]*fileJSON `json:"children,omitempty"`
+}
+
+func toJSON(f *Node) *fileJSON {
+	node := &fileJSON{
+		Name:    f.Name,
+		Size:    f.Size,
+		IsDir:   f.IsDir,
+		Mode:    f.Mode.String(),
+		ModTime: f.ModTime,
+		Link:    f.Link,
+		Cycle:   f.Cycle,
+		Total:   f.TotalSize,
+		Files:   f.Files,
+		Diff:    diffNames[f.Diff],
+		OldSize: f.OldSize,
+		Hash:    f.Hash,
+	}
+	if f.Err != nil {
+		node.Error = errLabel(f.Err)
+	}
+	if f.Children != nil {
+		children := make([]*fileJSON, 0, len(f.Children))
+		for _, child := range f.Children {
+			children = append(children, toJSON(child))
+		}
+		node.Children = &children
+	}
+	return node
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(out io.Writer, root *Node) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toJSON(root))
+}
+
+type yamlRenderer struct{}
+
+func (yamlRenderer) Render(out io.Writer, root *Node) error {
+	return printYAML(out, root, "")
+}
+
+type ndjsonRenderer struct{}
+
+func (ndjsonRenderer) Render(out io.Writer, root *Node) error {
+	if root.Children == nil {
+		return nil
+	}
+	return printNDJSON(json.NewEncoder(out), root.Children, "")
+}
+
+func printNDJSON(enc *json.Encoder, nodes []*Node, dir string) error {
+	for _, node := range nodes {
+		line := toJSON(node)
+		line.Path = path.Join(dir, node.Name)
+		line.Children = nil
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+		if node.Children != nil {
+			if err := printNDJSON(enc, node.Children, line.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printYAML пишет узел как элемент блочного yaml, indent - отступ полей узла
+func printYAML(out io.Writer, f *Node, indent string) error {
+	_, err := fmt.Fprintf(out, "name: %s\n%ssize: %d\n%sis_dir: %t\n%smode: %s\n%smtime: %s\n",
+		strconv.Quote(f.Name),
+		indent, f.Size,
+		indent, f.IsDir,
+		indent, strconv.Quote(f.Mode.String()),
+		indent, f.ModTime.Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	errText := ""
+	if f.Err != nil {
+		errText = errLabel(f.Err)
+	}
+	optional := []struct {
+		key   string
+		value string
+		set   bool
+	}{
+		{"link", strconv.Quote(f.Link), f.Link != ""},
+		{"cycle", "true", f.Cycle},
+		{"error", strconv.Quote(errText), f.Err != nil},
+		{"total_size", strconv.FormatInt(f.TotalSize, 10), f.TotalSize != 0},
+		{"files", strconv.Itoa(f.Files), f.Files != 0},
+		{"diff", strconv.Quote(diffNames[f.Diff]), f.Diff != DiffNone},
+		{"old_size", strconv.FormatInt(f.OldSize, 10), f.OldSize != 0},
+		{"sha256", f.Hash, f.Hash != ""},
+	}
+	for _, field := range optional {
+		if !field.set {
+			continue
+		}
+		if _, err = fmt.Fprintf(out, "%s%s: %s\n", indent, field.key, field.value); err != nil {
+			return err
+		}
+	}
+	if f.Children == nil {
+		return nil
+	}
+	if len(f.Children) == 0 {
+		_, err = fmt.Fprintf(out, "%schildren: []\n", indent)
+		return err
+	}
+	if _, err = fmt.Fprintf(out, "%schildren:\n", indent); err != nil {
+		return err
+	}
+	for _, child := range f.Children {
+		if _, err = fmt.Fprintf(out, "%s  - ", indent); err != nil {
+			return err
+		}
+		if err = printYAML(out, child, indent+"    "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package tree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// openFS открывает каталог, zip или tar(.gz) архив, манифест как fs.FS
+func openFS(name string) (fs.FS, func() error, error) {
+	noop := func() error { return nil }
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, noop, err
+	}
+	if info.IsDir() {
+		return os.DirFS(name), noop, nil
+	}
+
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		r, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, noop, err
+		}
+		return r, r.Close, nil
+	case strings.HasSuffix(lower, ".tar"):
+		fsys, err := readTarFS(name, false)
+		return fsys, noop, err
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fsys, err := readTarFS(name, true)
+		return fsys, noop, err
+	case strings.HasSuffix(lower, ".manifest"):
+		fsys, err := readManifestFile(name)
+		return fsys, noop, err
+	}
+	return nil, noop, fmt.Errorf("%s is not a directory, a zip/tar archive or a manifest", name)
+}
+
+// readTarFS читает архив целиком в память: tar не умеет произвольный доступ
+func readTarFS(name string, gzipped bool) (*memFS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	fsys := newMemFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		entry := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if entry == "." {
+			continue
+		}
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			_, err = fsys.add(entry, info.Mode(), info.ModTime(), nil, "")
+		case tar.TypeSymlink:
+			_, err = fsys.add(entry, info.Mode(), info.ModTime(), nil, hdr.Linkname)
+		case tar.TypeLink:
+			target, lookupErr := fsys.lookup(path.Clean(hdr.Linkname), false)
+			if lookupErr != nil {
+				return nil, fmt.Errorf("%s: hard link %s: %v", name, entry, lookupErr)
+			}
+			_, err = fsys.add(entry, target.mode, info.ModTime(), target.data, "")
+		case tar.TypeReg:
+			data, readErr := io.ReadAll(tr)
+			if readErr != nil {
+				return nil, fmt.Errorf("%s: %v", name, readErr)
+			}
+			_, err = fsys.add(entry, info.Mode(), info.ModTime(), data, "")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+}
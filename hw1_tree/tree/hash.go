@@ -0,0 +1,133 @@
+package tree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const shortHashLen = 12
+
+// hashPrefix - перед hash в манифесте и в коротком виде
+const hashPrefix = "sha256:"
+
+func (w *walker) hashing() bool {
+	return w.opts.Hash || w.opts.Duplicates || w.verify
+}
+
+func (w *walker) hashFile(node *Node, name string) error {
+	if !node.Mode.IsRegular() {
+		return nil
+	}
+	if hash, ok := w.storedHash(name); ok {
+		node.Hash = hash
+		return nil
+	}
+	f, err := w.fsys.Open(name)
+	if err != nil {
+		return w.fail(node, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return w.fail(node, err)
+	}
+	node.Hash = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// storedHash - hash из манифеста для файла, содержимого которого нет в памяти
+func (w *walker) storedHash(name string) (string, bool) {
+	m, ok := w.fsys.(*memFS)
+	if !ok {
+		return "", false
+	}
+	return m.storedHash(name)
+}
+
+// contentLost - файл из манифеста, у которого сохранены только размер и hash
+func (w *walker) contentLost(name string) bool {
+	_, ok := w.storedHash(name)
+	return ok
+}
+
+func shortHash(hash string) string {
+	if len(hash) > shortHashLen {
+		hash = hash[:shortHashLen]
+	}
+	return hashPrefix + hash
+}
+
+type duplicates struct {
+	hash  string
+	size  int64
+	paths []string
+}
+
+// findDuplicates группирует непустые файлы с одинаковым хешем, крупные группы сверху
+func findDuplicates(root *Node) []duplicates {
+	byHash := map[string]*duplicates{}
+	var collect func(dir *Node, rel string)
+	collect = func(dir *Node, rel string) {
+		for _, child := range dir.Children {
+			path := joinRel(rel, child.Name)
+			if child.IsDir {
+				collect(child, path)
+				continue
+			}
+			if child.Hash == "" || child.Size == 0 {
+				continue
+			}
+			group, ok := byHash[child.Hash]
+			if !ok {
+				group = &duplicates{hash: child.Hash, size: child.Size}
+				byHash[child.Hash] = group
+			}
+			group.paths = append(group.paths, path)
+		}
+	}
+	if root.Children != nil {
+		collect(root, "")
+	}
+
+	groups := []duplicates{}
+	for _, group := range byHash {
+		if len(group.paths) > 1 {
+			sort.Strings(group.paths)
+			groups = append(groups, *group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].size != groups[j].size {
+			return groups[i].size > groups[j].size
+		}
+		return groups[i].paths[0] < groups[j].paths[0]
+	})
+	return groups
+}
+
+func printDuplicates(out io.Writer, root *Node, opts *Options) error {
+	groups := findDuplicates(root)
+	header := "\nduplicate files:\n"
+	if len(groups) == 0 {
+		header = "\nno duplicate files\n"
+	}
+	if _, err := io.WriteString(out, header); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		_, err := fmt.Fprintf(out, "%s, %s, %d files:\n", shortHash(group.hash), formatSize(group.size, opts.Units), len(group.paths))
+		if err != nil {
+			return err
+		}
+		for _, path := range group.paths {
+			if _, err := fmt.Fprintf(out, "\t%s\n", path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package tree
+
+import "io/fs"
+
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// getFileID достаёт устройство и inode; fs.FS без них (zip) циклы не проверяет
+func getFileID(info fs.FileInfo) (fileID, bool) {
+	if id, ok := info.Sys().(fileID); ok {
+		return id, true
+	}
+	return sysFileID(info)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package tree
+
+import "io/fs"
+
+func sysFileID(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+func sysOwner(info fs.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,331 @@
+package tree
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const manifestHeader = "# tree manifest v1"
+
+// ManifestInline - файлы не больше этого размера по умолчанию сохраняются в манифест
+// с содержимым, у остальных размер и sha256, при Materialize они заполняются нулями
+const ManifestInline = 4096
+
+// WriteManifest сохраняет дерево root в манифест: по строке на каталог, файл или симлинк.
+// Порог содержимого задаёт opts.InlineSize
+//
+//	d 755 "static"
+//	f 644 5 "static/a.txt" aGVsbG8=
+//	f 644 70372 "static/gopher.png" sha256:9a1e...
+//	l "static/link" "a.txt"
+func WriteManifest(ctx context.Context, out io.Writer, root string, opts Options) error {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return err
+	}
+	fsys, closeFS, err := openFS(root)
+	if err != nil {
+		return err
+	}
+	defer closeFS()
+
+	opts.PrintFiles = true
+	w := newWalker(ctx, fsys, opts)
+	node, err := w.buildRoot(root)
+	if err != nil {
+		return err
+	}
+	if !node.IsDir {
+		return fmt.Errorf("can't write manifest of %s: not a directory", root)
+	}
+	buf := bufio.NewWriter(out)
+	if _, err := fmt.Fprintln(buf, manifestHeader); err != nil {
+		return err
+	}
+	if err := w.writeManifest(buf, node.Children, ""); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return w.err()
+}
+
+func (w *walker) writeManifest(out io.Writer, nodes []*Node, dir string) error {
+	for _, node := range nodes {
+		rel := joinRel(dir, node.Name)
+		if node.Err != nil {
+			continue
+		}
+		var err error
+		switch {
+		case node.Mode&fs.ModeSymlink != 0:
+			_, err = fmt.Fprintf(out, "l %s %s\n", strconv.Quote(rel), strconv.Quote(node.Link))
+		case node.IsDir:
+			_, err = fmt.Fprintf(out, "d %o %s\n", node.Mode.Perm(), strconv.Quote(rel))
+			if err == nil {
+				err = w.writeManifest(out, node.Children, rel)
+			}
+		case node.Mode.IsRegular():
+			line := fmt.Sprintf("f %o %d %s", node.Mode.Perm(), node.Size, strconv.Quote(rel))
+			switch {
+			case node.Size > 0 && node.Size <= w.inlineSize() && !w.contentLost(rel):
+				data, readErr := fs.ReadFile(w.fsys, rel)
+				if readErr != nil {
+					if err := w.fail(node, readErr); err != nil {
+						return err
+					}
+					continue
+				}
+				line += " " + base64.StdEncoding.EncodeToString(data)
+			case node.Size > 0:
+				if node.Hash == "" {
+					if err := w.hashFile(node, rel); err != nil {
+						return err
+					}
+				}
+				if node.Err != nil {
+					continue
+				}
+				if node.Hash != "" {
+					line += " " + hashPrefix + node.Hash
+				}
+			}
+			_, err = fmt.Fprintln(out, line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineSize - до какого размера файлы сохраняются с содержимым
+func (w *walker) inlineSize() int64 {
+	if w.opts.InlineSize == 0 {
+		return ManifestInline
+	}
+	return w.opts.InlineSize
+}
+
+// ReadManifest разбирает манифест в fs.FS в памяти
+func ReadManifest(r io.Reader) (fs.FS, error) {
+	return readManifest(r)
+}
+
+func readManifestFile(name string) (*memFS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fsys, err := readManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return fsys, nil
+}
+
+func readManifest(r io.Reader) (*memFS, error) {
+	fsys := newMemFS()
+	// не bufio.Scanner: с большим -inline строки длиннее его буфера
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line != "" && !strings.HasPrefix(line, "#") {
+			if err := addManifestLine(fsys, line); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+		}
+		if err == io.EOF {
+			return fsys, nil
+		}
+	}
+}
+
+func addManifestLine(fsys *memFS, line string) error {
+	kind, rest, _ := strings.Cut(line, " ")
+	var perm, size uint64
+	var err error
+	if kind == "d" || kind == "f" {
+		var field string
+		field, rest, _ = strings.Cut(rest, " ")
+		if perm, err = strconv.ParseUint(field, 8, 32); err != nil {
+			return fmt.Errorf("bad mode %q", field)
+		}
+	}
+	if kind == "f" {
+		var field string
+		field, rest, _ = strings.Cut(rest, " ")
+		if size, err = strconv.ParseUint(field, 10, 63); err != nil {
+			return fmt.Errorf("bad size %q", field)
+		}
+	}
+	name, rest, err := unquoteField(rest)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "d":
+		_, err := fsys.add(name, fs.ModeDir|fs.FileMode(perm), time.Time{}, nil, "")
+		return err
+	case "f":
+		// в памяти только то содержимое, что записано в строке, у остальных файлов лишь размер
+		var data []byte
+		var hash string
+		switch {
+		case strings.HasPrefix(rest, hashPrefix):
+			hash = strings.TrimPrefix(rest, hashPrefix)
+			if sum, err := hex.DecodeString(hash); err != nil || len(sum) != sha256.Size {
+				return fmt.Errorf("%s: bad hash %q", name, rest)
+			}
+		case rest != "":
+			if data, err = base64.StdEncoding.DecodeString(rest); err != nil {
+				return err
+			}
+			if uint64(len(data)) != size {
+				return fmt.Errorf("%s: content is %d bytes, expected %d", name, len(data), size)
+			}
+		}
+		node, err := fsys.add(name, fs.FileMode(perm), time.Time{}, data, "")
+		if err != nil {
+			return err
+		}
+		node.size, node.hash = int64(size), hash
+		return nil
+	case "l":
+		target, _, err := unquoteField(rest)
+		if err != nil {
+			return err
+		}
+		_, err = fsys.add(name, 0777, time.Time{}, nil, target)
+		return err
+	}
+	return fmt.Errorf("unknown entry type %q", kind)
+}
+
+func unquoteField(s string) (string, string, error) {
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", "", fmt.Errorf("bad path %q", s)
+	}
+	value, err := strconv.Unquote(quoted)
+	return value, strings.TrimPrefix(s[len(quoted):], " "), err
+}
+
+// CheckManifest сравнивает каталог dir с манифестом: состав, размеры и содержимое
+// по сохранённому тексту или sha256. Выводит объединённое дерево, как Diff
+func CheckManifest(ctx context.Context, out io.Writer, manifest io.Reader, dir string, opts Options) (DiffStats, error) {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return DiffStats{}, err
+	}
+	fsys, err := readManifest(manifest)
+	if err != nil {
+		return DiffStats{}, err
+	}
+	opts.PrintFiles = true
+	w := newWalker(ctx, fsys, opts)
+	w.verify = true
+	expected, err := w.buildRoot(dir)
+	if err != nil {
+		return DiffStats{}, err
+	}
+	actual, err := w.loadRoot(dir)
+	if err != nil {
+		return DiffStats{}, err
+	}
+	if !actual.IsDir {
+		return DiffStats{}, fmt.Errorf("can't check %s: not a directory", dir)
+	}
+	return w.diff(out, expected, actual)
+}
+
+// Materialize создаёт по манифесту дерево в каталоге dir
+func Materialize(r io.Reader, dir string) error {
+	fsys, err := readManifest(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// права каталогов ставим в конце, иначе в каталог без w не записать содержимое
+	type dirPerm struct {
+		path string
+		perm fs.FileMode
+	}
+	dirs := []dirPerm{}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := fsys.ReadLink(name)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.IsDir():
+			dirs = append(dirs, dirPerm{target, info.Mode().Perm()})
+			return os.MkdirAll(target, 0755)
+		}
+		node, err := fsys.lookup(name, false)
+		if err != nil {
+			return err
+		}
+		if err := writeFixture(target, node); err != nil {
+			return err
+		}
+		return os.Chmod(target, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFixture записывает файл манифеста; если содержимого в манифесте нет,
+// файл нужного размера заполняется нулями без чтения в память
+func writeFixture(target string, node *memNode) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(node.data); err != nil {
+		f.Close()
+		return err
+	}
+	if node.partial() {
+		if err := f.Truncate(node.size); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
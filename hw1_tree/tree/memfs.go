@@ -0,0 +1,255 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxLinkHops = 40
+
+// memDev - номер "устройства" для inode узлов memFS
+const memDev = ^uint64(0)
+
+// memFS - fs.FS в памяти, нужна для tar архивов и тестовых деревьев
+type memFS struct {
+	root    *memNode
+	lastIno uint64
+}
+
+type memNode struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	data     []byte
+	link     string
+	ino      uint64
+	children map[string]*memNode
+
+	// size может быть больше len(data): манифест хранит содержимое только небольших
+	// файлов, у остальных размер и hash - sha256 содержимого
+	size int64
+	hash string
+}
+
+func newMemFS() *memFS {
+	m := &memFS{}
+	m.root = m.newNode(".", fs.ModeDir|0755, time.Time{})
+	return m
+}
+
+func (m *memFS) newNode(name string, mode fs.FileMode, modTime time.Time) *memNode {
+	m.lastIno++
+	node := &memNode{name: name, mode: mode, modTime: modTime, ino: m.lastIno}
+	if mode.IsDir() {
+		node.children = map[string]*memNode{}
+	}
+	return node
+}
+
+// add создаёт файл, каталог или симлинк (link != ""), недостающие каталоги создаются сами
+func (m *memFS) add(name string, mode fs.FileMode, modTime time.Time, data []byte, link string) (*memNode, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "add", Path: name, Err: fs.ErrInvalid}
+	}
+	dir := m.root
+	parts := strings.Split(name, "/")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := dir.children[part]
+		if !ok {
+			next = m.newNode(part, fs.ModeDir|0755, modTime)
+			dir.children[part] = next
+		}
+		if !next.mode.IsDir() {
+			return nil, &fs.PathError{Op: "add", Path: name, Err: errors.New("not a directory")}
+		}
+		dir = next
+	}
+
+	base := parts[len(parts)-1]
+	if old, ok := dir.children[base]; ok && old.mode.IsDir() && mode.IsDir() {
+		old.mode, old.modTime = mode, modTime
+		return old, nil
+	}
+	if link != "" {
+		mode = fs.ModeSymlink | mode.Perm()
+	}
+	node := m.newNode(base, mode, modTime)
+	node.data, node.size, node.link = data, int64(len(data)), link
+	dir.children[base] = node
+	return node, nil
+}
+
+func (m *memFS) lookup(name string, follow bool) (*memNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	node, hops := m.root, 0
+	parts := []string{}
+	if name != "." {
+		parts = strings.Split(name, "/")
+	}
+	for i := 0; i < len(parts); i++ {
+		if !node.mode.IsDir() {
+			return nil, fs.ErrNotExist
+		}
+		next, ok := node.children[parts[i]]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		if next.link != "" && (follow || i < len(parts)-1) {
+			if hops++; hops > maxLinkHops {
+				return nil, errors.New("too many levels of symbolic links")
+			}
+			target := path.Join(path.Join(parts[:i]...), next.link)
+			if strings.HasPrefix(next.link, "/") {
+				target = path.Clean(strings.TrimPrefix(next.link, "/"))
+			}
+			if target == ".." || strings.HasPrefix(target, "../") {
+				return nil, fs.ErrNotExist
+			}
+			rest := parts[i+1:]
+			parts = append(strings.Split(target, "/"), rest...)
+			if target == "." {
+				parts = rest
+			}
+			node, i = m.root, -1
+			continue
+		}
+		node = next
+	}
+	return node, nil
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	node, err := m.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &memFile{node: node, reader: node.open()}, nil
+}
+
+// open читает содержимое узла, несохранённая часть читается нулями
+func (n *memNode) open() io.Reader {
+	if !n.partial() {
+		return bytes.NewReader(n.data)
+	}
+	return io.MultiReader(bytes.NewReader(n.data), io.LimitReader(zeros{}, n.size-int64(len(n.data))))
+}
+
+// partial - содержимое файла известно только по размеру и hash
+func (n *memNode) partial() bool {
+	return int64(len(n.data)) < n.size
+}
+
+// storedHash отдаёт sha256 из манифеста для файлов, содержимого которых нет в памяти.
+// ok == false - содержимое есть и его можно прочитать
+func (m *memFS) storedHash(name string) (hash string, ok bool) {
+	node, err := m.lookup(name, true)
+	if err != nil || !node.partial() {
+		return "", false
+	}
+	return node.hash, true
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	node, err := m.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return node, nil
+}
+
+func (m *memFS) Lstat(name string) (fs.FileInfo, error) {
+	node, err := m.lookup(name, false)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	return node, nil
+}
+
+func (m *memFS) ReadLink(name string) (string, error) {
+	node, err := m.lookup(name, false)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	if node.link == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return node.link, nil
+}
+
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	node, err := m.lookup(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return node.entries(), nil
+}
+
+func (n *memNode) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+func (n *memNode) Name() string       { return n.name }
+func (n *memNode) Size() int64        { return n.size }
+func (n *memNode) Mode() fs.FileMode  { return n.mode }
+func (n *memNode) ModTime() time.Time { return n.modTime }
+func (n *memNode) IsDir() bool        { return n.mode.IsDir() }
+func (n *memNode) Sys() interface{}   { return fileID{dev: memDev, ino: n.ino} }
+
+type memFile struct {
+	node    *memNode
+	reader  io.Reader
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.node, nil }
+func (f *memFile) Read(p []byte) (int, error) { return f.reader.Read(p) }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.node.name, Err: errors.New("not a directory")}
+	}
+	if !f.read {
+		f.entries, f.read = f.node.entries(), true
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,97 @@
+package tree
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTimeFormat = "Jan _2 15:04"
+
+// timeFormats - короткие имена для -timefmt, всё остальное считается layout'ом пакета time
+var timeFormats = map[string]string{
+	"default": defaultTimeFormat,
+	"iso":     "2006-01-02 15:04",
+	"full":    "2006-01-02 15:04:05",
+	"rfc3339": time.RFC3339,
+}
+
+func ParseTimeFormat(name string) string {
+	if layout, ok := timeFormats[strings.ToLower(name)]; ok {
+		return layout
+	}
+	return name
+}
+
+var ownerNames = struct {
+	sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+}{users: map[uint32]string{}, groups: map[uint32]string{}}
+
+func userName(uid uint32) string {
+	ownerNames.Lock()
+	defer ownerNames.Unlock()
+	if name, ok := ownerNames.users[uid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	ownerNames.users[uid] = name
+	return name
+}
+
+func groupName(gid uint32) string {
+	ownerNames.Lock()
+	defer ownerNames.Unlock()
+	if name, ok := ownerNames.groups[gid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(gid), 10)
+	name := id
+	if g, err := user.LookupGroupId(id); err == nil {
+		name = g.Name
+	}
+	ownerNames.groups[gid] = name
+	return name
+}
+
+func (o *Options) hasColumns() bool {
+	return o.Perms || o.Owner || o.Group || o.ModTimes
+}
+
+// metaColumns - колонки перед именем, как у tree -p -u -g -D: "[-rw-r--r-- root     Jan  2 15:04]  "
+func metaColumns(node *Node, opts *Options) string {
+	columns := []string{}
+	if opts.Perms {
+		columns = append(columns, node.Mode.String())
+	}
+	if opts.Owner {
+		owner := "?"
+		if node.hasOwner {
+			owner = userName(node.uid)
+		}
+		columns = append(columns, fmt.Sprintf("%-8s", owner))
+	}
+	if opts.Group {
+		group := "?"
+		if node.hasOwner {
+			group = groupName(node.gid)
+		}
+		columns = append(columns, fmt.Sprintf("%-8s", group))
+	}
+	if opts.ModTimes {
+		layout := opts.TimeFormat
+		if layout == "" {
+			layout = defaultTimeFormat
+		}
+		columns = append(columns, node.ModTime.Format(layout))
+	}
+	return "[" + strings.Join(columns, " ") + "]  "
+}
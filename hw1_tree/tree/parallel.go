@@ -0,0 +1,102 @@
+package tree
+
+import (
+	"sort"
+	"sync"
+)
+
+type dirJob struct {
+	dir *Node
+	l   *level
+}
+
+// dirPool - очередь каталогов на чтение для фиксированного числа воркеров.
+// Каждый воркер сам кладёт в очередь найденные подкаталоги, обход заканчивается
+// когда pending (в очереди + в работе) доходит до нуля
+type dirPool struct {
+	w       *walker
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []dirJob
+	pending int
+	err     error
+}
+
+func (w *walker) walkParallel(root *Node, l *level) error {
+	pool := &dirPool{w: w}
+	pool.cond = sync.NewCond(&pool.mu)
+	pool.push(dirJob{root, l})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < w.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.work()
+		}()
+	}
+	wg.Wait()
+
+	sort.SliceStable(w.errs, func(i, j int) bool {
+		return w.errs[i].Error() < w.errs[j].Error()
+	})
+	return pool.err
+}
+
+func (p *dirPool) push(job dirJob) {
+	p.mu.Lock()
+	p.queue = append(p.queue, job)
+	p.pending++
+	p.mu.Unlock()
+	p.cond.Signal()
+}
+
+func (p *dirPool) work() {
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && p.pending > 0 && p.err == nil {
+			p.cond.Wait()
+		}
+		if p.pending == 0 || p.err != nil {
+			p.mu.Unlock()
+			return
+		}
+		job := p.queue[len(p.queue)-1]
+		p.queue = p.queue[:len(p.queue)-1]
+		p.mu.Unlock()
+
+		err := p.read(job)
+
+		p.mu.Lock()
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		p.pending--
+		done := p.pending == 0 || p.err != nil
+		p.mu.Unlock()
+		if done {
+			p.cond.Broadcast()
+		}
+	}
+}
+
+func (p *dirPool) read(job dirJob) error {
+	if err := p.w.ctx.Err(); err != nil {
+		return err
+	}
+	files, err := p.w.readDir(job.dir, job.l)
+	if err != nil {
+		return err
+	}
+	job.dir.Children = files
+	for _, file := range files {
+		err := p.w.descend(file, job.l, func(dir *Node, l *level) error {
+			p.push(dirJob{dir, l})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
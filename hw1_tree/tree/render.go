@@ -0,0 +1,204 @@
+package tree
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Renderer interface {
+	Render(out io.Writer, root *Node) error
+}
+
+type Style int
+
+const (
+	StyleClassic Style = iota
+	StyleASCII
+	StyleUnicode
+	StyleMarkdown
+	StyleHTML
+)
+
+var styleNames = map[Style]string{
+	StyleClassic:  "classic",
+	StyleASCII:    "ascii",
+	StyleUnicode:  "unicode",
+	StyleMarkdown: "markdown",
+	StyleHTML:     "html",
+}
+
+func (s Style) String() string {
+	if name, ok := styleNames[s]; ok {
+		return name
+	}
+	return "Style(" + strconv.Itoa(int(s)) + ")"
+}
+
+// IsTree - стиль рисует ветки псевдографикой, а не разметкой
+func (s Style) IsTree() bool {
+	_, ok := treeStyles[s]
+	return ok
+}
+
+func ParseStyle(name string) (Style, error) {
+	for s, n := range styleNames {
+		if n == strings.ToLower(name) {
+			return s, nil
+		}
+	}
+	return StyleClassic, fmt.Errorf("unknown style %q", name)
+}
+
+func newRenderer(opts *Options) (Renderer, error) {
+	switch opts.Format {
+	case FormatJSON:
+		return jsonRenderer{}, nil
+	case FormatYAML:
+		return yamlRenderer{}, nil
+	case FormatNDJSON:
+		return ndjsonRenderer{}, nil
+	case FormatText:
+	default:
+		return nil, fmt.Errorf("unknown format %v", opts.Format)
+	}
+	if style, ok := treeStyles[opts.Style]; ok {
+		return &textRenderer{style: style, opts: opts}, nil
+	}
+	switch opts.Style {
+	case StyleMarkdown:
+		return &markdownRenderer{opts: opts}, nil
+	case StyleHTML:
+		return &htmlRenderer{opts: opts}, nil
+	}
+	return nil, fmt.Errorf("unknown style %v", opts.Style)
+}
+
+// treeStyle - символы графики: ветка, последняя ветка и отступы под ними
+type treeStyle struct {
+	branch string
+	last   string
+	pipe   string
+	blank  string
+}
+
+var treeStyles = map[Style]treeStyle{
+	StyleClassic: {"├───", "└───", "│\t", "\t"},
+	StyleASCII:   {"|-- ", "`-- ", "|   ", "    "},
+	StyleUnicode: {"├── ", "└── ", "│   ", "    "},
+}
+
+func (s treeStyle) prefix(last []bool) string {
+	prefix := strings.Builder{}
+	for _, l := range last[:len(last)-1] {
+		if l {
+			prefix.WriteString(s.blank)
+		} else {
+			prefix.WriteString(s.pipe)
+		}
+	}
+	if last[len(last)-1] {
+		prefix.WriteString(s.last)
+	} else {
+		prefix.WriteString(s.branch)
+	}
+	return prefix.String()
+}
+
+type textRenderer struct {
+	style treeStyle
+	opts  *Options
+}
+
+func (r *textRenderer) Render(out io.Writer, root *Node) error {
+	if root.Children == nil {
+		return nil
+	}
+	return r.printTree(out, root.Children, "")
+}
+
+func (r *textRenderer) printTree(out io.Writer, nodes []*Node, prefix string) error {
+	for i, node := range nodes {
+		branch, indent := r.style.branch, r.style.pipe
+		if i == len(nodes)-1 {
+			branch, indent = r.style.last, r.style.blank
+		}
+		if _, err := fmt.Fprintf(out, "%s%s%s\n", prefix, branch, fileLabel(node, r.opts)); err != nil {
+			return err
+		}
+		if node.IsDir {
+			if err := r.printTree(out, node.Children, prefix+indent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type markdownRenderer struct {
+	opts *Options
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func (r *markdownRenderer) Render(out io.Writer, root *Node) error {
+	if root.Children == nil {
+		return nil
+	}
+	return r.printList(out, root.Children, "")
+}
+
+func (r *markdownRenderer) printList(out io.Writer, nodes []*Node, indent string) error {
+	for _, node := range nodes {
+		if _, err := fmt.Fprintf(out, "%s- %s\n", indent, markdownEscaper.Replace(fileLabel(node, r.opts))); err != nil {
+			return err
+		}
+		if node.IsDir {
+			if err := r.printList(out, node.Children, indent+"  "); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type htmlRenderer struct {
+	opts *Options
+}
+
+func (r *htmlRenderer) Render(out io.Writer, root *Node) error {
+	if root.Children == nil {
+		return nil
+	}
+	return r.printList(out, root.Children, "")
+}
+
+func (r *htmlRenderer) printList(out io.Writer, nodes []*Node, indent string) error {
+	if _, err := fmt.Fprintf(out, "%s<ul>\n", indent); err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		label := html.EscapeString(fileLabel(node, r.opts))
+		if !node.IsDir || len(node.Children) == 0 {
+			if _, err := fmt.Fprintf(out, "%s  <li>%s</li>\n", indent, label); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "%s  <li>%s\n", indent, label); err != nil {
+			return err
+		}
+		if err := r.printList(out, node.Children, indent+"    "); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "%s  </li>\n", indent); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(out, "%s</ul>\n", indent)
+	return err
+}
@@ -0,0 +1,130 @@
+package tree
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	highlightStart = "\x1b[1;31m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// GlobRegexp переводит glob-шаблон в регулярное выражение на всё имя. Синтаксис как в shell:
+// *, ?, классы [...] с отрицанием [!...] или [^...], \ экранирует следующий символ.
+// По тем же правилам сравниваются -include, -exclude и .gitignore
+func GlobRegexp(pattern string) (*regexp.Regexp, error) {
+	expr := strings.Builder{}
+	expr.WriteString("(?s)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			class, size, err := globClass(pattern[i:])
+			if err != nil {
+				return nil, err
+			}
+			expr.WriteString(class)
+			i += size - 1
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, path.ErrBadPattern
+			}
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// globs - уже переведённые шаблоны -include, -exclude и .gitignore
+var globs sync.Map
+
+// matchName сравнивает имя с glob-шаблоном, ошибочный шаблон ни с чем не совпадает
+func matchName(pattern, name string) bool {
+	if re, ok := globs.Load(pattern); ok {
+		return re.(*regexp.Regexp).MatchString(name)
+	}
+	re, err := GlobRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	globs.Store(pattern, re)
+	return re.MatchString(name)
+}
+
+// globClass переводит класс [...] в начале pattern и возвращает его длину в шаблоне.
+// Отрицание пишется как [!...] или [^...], \ экранирует следующий символ
+func globClass(pattern string) (string, int, error) {
+	class := strings.Builder{}
+	class.WriteString("[")
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		class.WriteString("^")
+		i++
+	}
+	start := i
+	for i < len(pattern) {
+		c := pattern[i]
+		switch {
+		case c == ']' && i == start:
+			return "", 0, path.ErrBadPattern
+		case c == ']':
+			class.WriteString("]")
+			return class.String(), i + 1, nil
+		case c == '\\' && i+1 < len(pattern):
+			r, size := utf8.DecodeRuneInString(pattern[i+1:])
+			if r < utf8.RuneSelf && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				class.WriteByte('\\')
+			}
+			class.WriteString(pattern[i+1 : i+1+size])
+			i += 1 + size
+			continue
+		case c == '[' || c == '^' || c == '\\':
+			class.WriteByte('\\')
+			class.WriteByte(c)
+		default:
+			class.WriteByte(c)
+		}
+		i++
+	}
+	return "", 0, path.ErrBadPattern
+}
+
+// prune оставляет только совпавшие узлы и каталоги, в которых они есть, и пересчитывает статистику
+func (w *walker) prune(dir *Node) bool {
+	kept := []*Node{}
+	for _, child := range dir.Children {
+		matched := w.opts.Find.MatchString(child.Name)
+		if child.IsDir && w.prune(child) {
+			matched = true
+		}
+		if matched {
+			kept = append(kept, child)
+		}
+	}
+	dir.Children = kept
+	for _, child := range kept {
+		w.count(child)
+	}
+	return len(kept) > 0
+}
+
+func highlight(name string, re *regexp.Regexp) string {
+	return re.ReplaceAllStringFunc(name, func(match string) string {
+		if match == "" {
+			return match
+		}
+		return highlightStart + match + highlightEnd
+	})
+}
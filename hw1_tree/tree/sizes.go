@@ -0,0 +1,101 @@
+package tree
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+)
+
+type Units int
+
+const (
+	UnitsBytes Units = iota
+	UnitsIEC
+	UnitsSI
+)
+
+func ParseUnits(name string) (Units, error) {
+	switch name {
+	case "", "bytes", "b":
+		return UnitsBytes, nil
+	case "iec":
+		return UnitsIEC, nil
+	case "si":
+		return UnitsSI, nil
+	}
+	return UnitsBytes, fmt.Errorf("unknown units %q", name)
+}
+
+func formatSize(size int64, units Units) string {
+	if size == 0 {
+		return "empty"
+	}
+	var base int64
+	var prefixes, suffix string
+	switch units {
+	case UnitsIEC:
+		base, prefixes, suffix = 1024, "KMGTPE", "iB"
+	case UnitsSI:
+		base, prefixes, suffix = 1000, "kMGTPE", "B"
+	default:
+		return strconv.FormatInt(size, 10) + "b"
+	}
+	if size < base {
+		return strconv.FormatInt(size, 10) + "B"
+	}
+	value, i := float64(size)/float64(base), 0
+	for value >= float64(base) && i < len(prefixes)-1 {
+		value /= float64(base)
+		i++
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + prefixes[i:i+1] + suffix
+}
+
+func dirSummary(node *Node, opts *Options) string {
+	switch {
+	case opts.DirSizes && opts.FileCounts:
+		return " (" + formatSize(node.TotalSize, opts.Units) + ", " + filesCount(node.Files) + ")"
+	case opts.DirSizes:
+		return " (" + formatSize(node.TotalSize, opts.Units) + ")"
+	case opts.FileCounts:
+		return " (" + filesCount(node.Files) + ")"
+	}
+	return ""
+}
+
+func filesCount(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return strconv.Itoa(n) + " files"
+}
+
+// countHidden учитывает в итогах каталога файлы, которые не попадают в вывод без -f
+func (w *walker) countHidden(dir *Node, entry fs.DirEntry) {
+	if !w.opts.DirSizes && !w.opts.FileCounts {
+		return
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return
+	}
+	dir.Files++
+	if info.Mode()&fs.ModeSymlink == 0 {
+		dir.TotalSize += info.Size()
+	}
+}
+
+func aggregate(dir *Node) {
+	for _, child := range dir.Children {
+		if child.IsDir {
+			aggregate(child)
+			dir.TotalSize += child.TotalSize
+			dir.Files += child.Files
+			continue
+		}
+		dir.Files++
+		if child.Mode&fs.ModeSymlink == 0 {
+			dir.TotalSize += child.Size
+		}
+	}
+}
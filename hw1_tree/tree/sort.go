@@ -0,0 +1,74 @@
+package tree
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+type SortOrder int
+
+const (
+	SortName SortOrder = iota
+	SortSize
+	SortMtime
+	SortExt
+)
+
+func ParseSortOrder(name string) (SortOrder, error) {
+	switch name {
+	case "", "name":
+		return SortName, nil
+	case "size":
+		return SortSize, nil
+	case "mtime", "time":
+		return SortMtime, nil
+	case "ext", "extension":
+		return SortExt, nil
+	}
+	return SortName, fmt.Errorf("unknown sort order %q", name)
+}
+
+// sortFiles - по размеру и времени как в ls: большие и новые сверху
+func sortFiles(files []*Node, opts *Options) {
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if opts.DirsFirst && a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if opts.Reverse {
+			a, b = b, a
+		}
+		switch opts.Sort {
+		case SortSize:
+			if sizeOf(a) != sizeOf(b) {
+				return sizeOf(a) > sizeOf(b)
+			}
+		case SortMtime:
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+		case SortExt:
+			if extA, extB := filepath.Ext(a.Name), filepath.Ext(b.Name); extA != extB {
+				return extA < extB
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+func sizeOf(f *Node) int64 {
+	if f.IsDir {
+		return f.TotalSize
+	}
+	return f.Size
+}
+
+func sortTree(dir *Node, opts *Options) {
+	sortFiles(dir.Children, opts)
+	for _, child := range dir.Children {
+		if child.IsDir {
+			sortTree(child, opts)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package tree
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+// Entry - узел, который отдаёт потоковый обход. Last[i] - является ли предок
+// на глубине i+1 последним у своего родителя, Last[len(Last)-1] относится к самому узлу
+type Entry struct {
+	Node *Node
+	Path string
+	Last []bool
+}
+
+func (e *Entry) Depth() int {
+	return len(e.Last)
+}
+
+// WalkFunc может вернуть filepath.SkipDir, чтобы не раскрывать каталог
+type WalkFunc func(e *Entry) error
+
+// WalkEntries обходит root без построения дерева, отдавая узлы fn по мере чтения
+func WalkEntries(ctx context.Context, root string, opts Options, fn WalkFunc) error {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return err
+	}
+	fsys, closeFS, err := openFS(root)
+	if err != nil {
+		return err
+	}
+	defer closeFS()
+	w := newWalker(ctx, fsys, opts)
+	return w.walkStream(fn)
+}
+
+func (w *walker) walkStream(fn WalkFunc) error {
+	info, err := fs.Stat(w.fsys, ".")
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	root := newNode(info)
+	l := rootLevel(root)
+	files, err := w.readDir(root, l)
+	if err != nil {
+		return err
+	}
+	if err := w.stream(l, nil, files, fn); err != nil {
+		return err
+	}
+	return w.err()
+}
+
+// stream читает содержимое каталога до вызова fn, чтобы ошибка чтения попала в Entry
+func (w *walker) stream(l *level, last []bool, files []*Node, fn WalkFunc) error {
+	for i, file := range files {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		files[i] = nil
+		entry := &Entry{
+			Node: file,
+			Path: joinRel(l.rel, file.Name),
+			Last: append(last[:len(last):len(last)], i == len(files)-1),
+		}
+
+		var childs []*Node
+		var child *level
+		if w.enter(file, l) {
+			child = l.child(file)
+			var err error
+			childs, err = w.readDir(file, child)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := fn(entry)
+		if err == filepath.SkipDir {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if child != nil {
+			if err := w.stream(child, entry.Last, childs, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckStream проверяет, что вывод с opts можно печатать потоком, не строя дерево
+func CheckStream(opts Options) error {
+	if opts.DirSizes || opts.FileCounts || opts.Duplicates {
+		return fmt.Errorf("directory totals need the whole tree and can't be streamed")
+	}
+	if opts.Find != nil {
+		return fmt.Errorf("search needs the whole tree and can't be streamed")
+	}
+	switch opts.Format {
+	case FormatText:
+		if _, ok := treeStyles[opts.Style]; !ok {
+			return fmt.Errorf("style %v does not support streaming", opts.Style)
+		}
+		return nil
+	case FormatNDJSON:
+		return nil
+	}
+	return fmt.Errorf("format %v does not support streaming", opts.Format)
+}
+
+func (w *walker) streamTree(out io.Writer) error {
+	if err := CheckStream(w.opts); err != nil {
+		return err
+	}
+	switch w.opts.Format {
+	case FormatText:
+		style := treeStyles[w.opts.Style]
+		return w.walkStream(func(e *Entry) error {
+			_, err := fmt.Fprintf(out, "%s%s\n", style.prefix(e.Last), fileLabel(e.Node, &w.opts))
+			return err
+		})
+	case FormatNDJSON:
+		enc := json.NewEncoder(out)
+		return w.walkStream(func(e *Entry) error {
+			line := toJSON(e.Node)
+			line.Path = e.Path
+			line.Children = nil
+			return enc.Encode(line)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+// Package tree строит и выводит дерево каталогов, как утилита tree
+package tree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Node - файл или каталог, у файлов Children == nil
+type Node struct {
+	Name      string
+	Size      int64
+	IsDir     bool
+	Mode      fs.FileMode
+	ModTime   time.Time
+	Link      string
+	Cycle     bool
+	Err       error
+	TotalSize int64
+	Files     int
+	Diff      DiffStatus
+	OldSize   int64
+	Hash      string
+	Children  []*Node
+
+	id       fileID
+	hasID    bool
+	uid      uint32
+	gid      uint32
+	hasOwner bool
+	// targetMode - тип файла, на который ведёт симлинк, для ln=target в LS_COLORS
+	targetMode fs.FileMode
+}
+
+type Options struct {
+	PrintFiles bool
+	// ShowHidden - показывать файлы и каталоги, начинающиеся с точки
+	ShowHidden bool
+	Format     Format
+	Style      Style
+	Include    []string
+	Exclude    []string
+	GitIgnore  bool
+	MaxDepth   int
+	Stream     bool
+	Strict     bool
+
+	FollowSymlinks bool
+	Workers        int
+
+	DirSizes   bool
+	FileCounts bool
+	Units      Units
+
+	Sort      SortOrder
+	DirsFirst bool
+	Reverse   bool
+
+	Hash       bool
+	Duplicates bool
+
+	// Find - показывать только совпавшие по имени узлы и путь к ним
+	Find      *regexp.Regexp
+	Highlight bool
+
+	// Colors - раскраска имён, nil - без цветов
+	Colors *ColorScheme
+
+	Perms      bool
+	Owner      bool
+	Group      bool
+	ModTimes   bool
+	TimeFormat string
+
+	// InlineSize - WriteManifest сохраняет содержимое файлов не больше этого размера,
+	// 0 - ManifestInline, меньше нуля - ни одного файла
+	InlineSize int64
+}
+
+type Stats struct {
+	Dirs  int
+	Files int
+}
+
+// Summary - итоговая строка как у tree: "N directories, M files"
+func (s Stats) Summary(withFiles bool) string {
+	dirs := fmt.Sprintf("%d directories", s.Dirs)
+	if s.Dirs == 1 {
+		dirs = "1 directory"
+	}
+	if !withFiles {
+		return dirs
+	}
+	return dirs + ", " + filesCount(s.Files)
+}
+
+type walker struct {
+	ctx   context.Context
+	opts  Options
+	fsys  fs.FS
+	mu    sync.Mutex
+	errs  []error
+	stats Stats
+	// verify - хешировать файлы для сравнения содержимого, не показывая hash
+	verify bool
+}
+
+func newWalker(ctx context.Context, fsys fs.FS, opts Options) *walker {
+	return &walker{ctx: ctx, opts: opts, fsys: fsys}
+}
+
+func newNode(info fs.FileInfo) *Node {
+	f := &Node{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		IsDir:   info.IsDir(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	f.id, f.hasID = getFileID(info)
+	f.uid, f.gid, f.hasOwner = sysOwner(info)
+	if f.IsDir {
+		f.Children = []*Node{}
+	}
+	return f
+}
+
+func (f *Node) String() string {
+	return f.Name
+}
+
+// level - каталог в процессе обхода, parent ведёт к корню и нужен для поиска циклов
+type level struct {
+	path    string
+	rel     string
+	depth   int
+	id      fileID
+	ignores *ignoreRules
+	parent  *level
+}
+
+func (l *level) child(node *Node) *level {
+	return &level{
+		path:    path.Join(l.path, node.Name),
+		rel:     joinRel(l.rel, node.Name),
+		depth:   l.depth + 1,
+		id:      node.id,
+		ignores: l.ignores,
+		parent:  l,
+	}
+}
+
+func (l *level) isAncestor(id fileID) bool {
+	for ; l != nil; l = l.parent {
+		if l.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *walker) readDir(dir *Node, l *level) ([]*Node, error) {
+	entries, err := fs.ReadDir(w.fsys, l.path)
+	if err != nil {
+		if err := w.fail(dir, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.opts.GitIgnore {
+		l.ignores, err = loadIgnoreRules(w.fsys, l.path, l.rel, l.ignores)
+		if err != nil {
+			if err := w.fail(dir, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	nodes := []*Node{}
+	for _, entry := range entries {
+		name := path.Join(l.path, entry.Name())
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 && w.opts.FollowSymlinks {
+			if info, err := fs.Stat(w.fsys, name); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if w.skip(joinRel(l.rel, entry.Name()), isDir, l.ignores) {
+			continue
+		}
+		if !isDir && !w.opts.PrintFiles {
+			w.countHidden(dir, entry)
+			continue
+		}
+		node, err := w.entryFile(entry, name)
+		if err != nil {
+			return nil, err
+		}
+		// узлы глубже MaxDepth читаются только ради итогов и в вывод не попадают
+		if w.expand(l.depth) {
+			if w.hashing() && node.Err == nil {
+				if err := w.hashFile(node, name); err != nil {
+					return nil, err
+				}
+			}
+			w.count(node)
+		}
+		nodes = append(nodes, node)
+	}
+	sortFiles(nodes, &w.opts)
+	return nodes, nil
+}
+
+func (w *walker) entryFile(entry fs.DirEntry, name string) (*Node, error) {
+	info, err := entry.Info()
+	if err != nil {
+		node := &Node{Name: entry.Name(), IsDir: entry.IsDir(), Mode: entry.Type()}
+		if node.IsDir {
+			node.Children = []*Node{}
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			err = &fs.PathError{Op: "lstat", Path: name, Err: errVanished}
+		}
+		return node, w.fail(node, err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return newNode(info), nil
+	}
+
+	node := newNode(info)
+	node.Link, err = fs.ReadLink(w.fsys, name)
+	if err != nil {
+		return node, w.fail(node, err)
+	}
+	target, err := fs.Stat(w.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = &fs.PathError{Op: "stat", Path: name, Err: errBrokenSymlink}
+		}
+		return node, w.fail(node, err)
+	}
+	node.targetMode = target.Mode()
+	if w.opts.FollowSymlinks {
+		link := node.Link
+		node = newNode(target)
+		node.Name, node.Link = entry.Name(), link
+	}
+	return node, nil
+}
+
+// enter решает, раскрывать ли каталог: не глубже MaxDepth и не по кругу через симлинки
+func (w *walker) enter(node *Node, l *level) bool {
+	if !node.IsDir || !w.expand(l.depth+1) {
+		return false
+	}
+	if node.hasID && l.isAncestor(node.id) {
+		node.Cycle = true
+		return false
+	}
+	return true
+}
+
+func (w *walker) expand(depth int) bool {
+	return w.opts.MaxDepth <= 0 || depth < w.opts.MaxDepth
+}
+
+// totalsOnly - каталог глубже MaxDepth, который всё равно надо обойти ради -du и -counts
+func (w *walker) totalsOnly(node *Node, l *level) bool {
+	if !w.opts.DirSizes && !w.opts.FileCounts {
+		return false
+	}
+	return node.IsDir && !w.expand(l.depth+1) && !(node.hasID && l.isAncestor(node.id))
+}
+
+// descend раскрывает каталог для вывода или, если он глубже MaxDepth, только считает его итоги
+func (w *walker) descend(node *Node, l *level, walk func(*Node, *level) error) error {
+	switch {
+	case w.enter(node, l):
+		return walk(node, l.child(node))
+	case w.totalsOnly(node, l):
+		return w.sumDir(node, l.child(node))
+	}
+	return nil
+}
+
+// sumDir записывает в TotalSize и Files итоги каталога, не оставляя в нём Children
+func (w *walker) sumDir(dir *Node, l *level) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	files, err := w.readDir(dir, l)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir {
+			if file.hasID && l.isAncestor(file.id) {
+				continue
+			}
+			if err := w.sumDir(file, l.child(file)); err != nil {
+				return err
+			}
+			dir.TotalSize += file.TotalSize
+			dir.Files += file.Files
+			continue
+		}
+		dir.Files++
+		if file.Mode&fs.ModeSymlink == 0 {
+			dir.TotalSize += file.Size
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkDir(dir *Node, l *level) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	files, err := w.readDir(dir, l)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := w.descend(file, l, w.walkDir); err != nil {
+			return err
+		}
+		dir.Children = append(dir.Children, file)
+	}
+	return nil
+}
+
+func (w *walker) count(node *Node) {
+	w.mu.Lock()
+	if node.IsDir {
+		w.stats.Dirs++
+	} else {
+		w.stats.Files++
+	}
+	w.mu.Unlock()
+}
+
+func rootLevel(root *Node) *level {
+	return &level{path: ".", id: root.id}
+}
+
+func fileLabel(node *Node, opts *Options) string {
+	name := node.Name
+	if opts.Highlight && opts.Find != nil {
+		name = highlight(name, opts.Find)
+	}
+	if opts.Colors != nil {
+		name = opts.Colors.colorize(name, node)
+	}
+	label := diffMarks[node.Diff] + name
+	if opts.hasColumns() {
+		label = metaColumns(node, opts) + label
+	}
+	if node.Link != "" {
+		label += " -> " + node.Link
+	}
+	if node.IsDir {
+		label += dirSummary(node, opts)
+	} else if node.Mode&fs.ModeSymlink == 0 {
+		size := formatSize(node.Size, opts.Units)
+		if node.Diff == DiffChanged {
+			size = formatSize(node.OldSize, opts.Units) + " -> " + size
+		}
+		if opts.Hash && node.Hash != "" {
+			size += ", " + shortHash(node.Hash)
+		}
+		label += " (" + size + ")"
+	}
+	if node.Err != nil {
+		label += " [" + errLabel(node.Err) + "]"
+	}
+	if node.Cycle {
+		label += " [recursive, not followed]"
+	}
+	return label
+}
+
+// Walk строит дерево каталога, zip или tar архива. Ошибки отдельных узлов
+// записываются в Node.Err и возвращаются вместе с деревом
+func Walk(ctx context.Context, root string, opts Options) (*Node, error) {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return nil, err
+	}
+	fsys, closeFS, err := openFS(root)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFS()
+	return WalkFS(ctx, fsys, root, opts)
+}
+
+// WalkFS строит дерево произвольной fs.FS, name - имя корня
+func WalkFS(ctx context.Context, fsys fs.FS, name string, opts Options) (*Node, error) {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return nil, err
+	}
+	w := newWalker(ctx, fsys, opts)
+	root, err := w.buildRoot(name)
+	if err != nil {
+		return nil, err
+	}
+	return root, w.err()
+}
+
+// Render выводит дерево стилем style без дополнительных колонок
+func Render(out io.Writer, node *Node, style Style) error {
+	return Fprint(out, node, Options{Style: style})
+}
+
+// Fprint выводит дерево в формате и со стилем из opts
+func Fprint(out io.Writer, node *Node, opts Options) error {
+	renderer, err := newRenderer(&opts)
+	if err != nil {
+		return err
+	}
+	if err := renderer.Render(out, node); err != nil {
+		return err
+	}
+	if opts.Duplicates && opts.Format == FormatText {
+		return printDuplicates(out, node, &opts)
+	}
+	return nil
+}
+
+// Print обходит root и сразу выводит его, с opts.Stream - не строя дерево в памяти
+func Print(ctx context.Context, out io.Writer, root string, opts Options) (Stats, error) {
+	fsys, closeFS, err := openFS(root)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer closeFS()
+	return PrintFS(ctx, out, fsys, root, opts)
+}
+
+// PrintFS - Print для произвольной fs.FS, name - подпись корня
+func PrintFS(ctx context.Context, out io.Writer, fsys fs.FS, name string, opts Options) (Stats, error) {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return Stats{}, err
+	}
+	w := newWalker(ctx, fsys, opts)
+	var err error
+	if opts.Stream {
+		err = w.streamTree(out)
+	} else {
+		err = w.printRoot(out, name)
+	}
+	return w.stats, err
+}
+
+func (w *walker) printRoot(out io.Writer, name string) error {
+	root, err := w.buildRoot(name)
+	if err != nil {
+		return err
+	}
+	if err := Fprint(out, root, w.opts); err != nil {
+		return err
+	}
+	return w.err()
+}
+
+func (w *walker) buildRoot(name string) (*Node, error) {
+	info, err := fs.Stat(w.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	root := newNode(info)
+	root.Name = name
+	if root.IsDir {
+		walk := w.walkDir
+		if w.opts.Workers > 1 {
+			walk = w.walkParallel
+		}
+		if err := walk(root, rootLevel(root)); err != nil {
+			return nil, err
+		}
+		if w.opts.Find != nil {
+			w.stats = Stats{}
+			w.prune(root)
+		}
+	}
+	if w.opts.DirSizes || w.opts.FileCounts {
+		aggregate(root)
+		if w.opts.Sort == SortSize && root.IsDir {
+			sortTree(root, &w.opts)
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,1172 @@
+package tree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const testData = "../testdata"
+
+func printPath(out io.Writer, path string, opts Options) error {
+	_, err := Print(context.Background(), out, path, opts)
+	return err
+}
+
+func TestTreeJSON(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printPath(out, testData+"/project", Options{PrintFiles: true, Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	root := fileJSON{}
+	if err := json.Unmarshal(out.Bytes(), &root); err != nil {
+		t.Fatalf("cant unpack json: %v", err)
+	}
+	if root.Name != testData+"/project" || !root.IsDir || root.Children == nil || len(*root.Children) != 2 {
+		t.Fatalf("bad root: %+v", root)
+	}
+	file := (*root.Children)[0]
+	if file.Name != "file.txt" || file.Size != 19 || file.IsDir || file.Mode[0] != '-' || file.ModTime.IsZero() {
+		t.Errorf("bad file node: %+v", file)
+	}
+}
+
+func TestTreeNDJSON(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printPath(out, testData, Options{Format: FormatNDJSON})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	paths := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		node := fileJSON{}
+		if err := json.Unmarshal([]byte(line), &node); err != nil {
+			t.Fatalf("cant unpack line %q: %v", line, err)
+		}
+		paths = append(paths, node.Path)
+	}
+	expected := "project static static/a_lorem static/a_lorem/ipsum static/css static/html static/js static/z_lorem static/z_lorem/ipsum zline zline/lorem zline/lorem/ipsum"
+	if result := strings.Join(paths, " "); result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+func TestTreeYAML(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printPath(out, testData+"/zline", Options{PrintFiles: true, Format: FormatYAML})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := out.String()
+	for _, expected := range []string{
+		"name: \"../testdata/zline\"\n",
+		"\n  - name: \"empty.txt\"\n    size: 0\n    is_dir: false\n",
+		"\n          - name: \"gopher.png\"\n            size: 70372\n",
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("yaml has no %q\nGot:\n%v", expected, result)
+		}
+	}
+}
+
+func makeTree(t testing.TB, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+const testFilterResult = `├───.gitignore (21b)
+├───cmd
+│	├───.gitignore (10b)
+│	├───keep.log (empty)
+│	└───main.go (empty)
+└───main.go (empty)
+`
+
+func TestTreeFilter(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		".gitignore":        "*.log\nbuild/\n/tmp.go\n",
+		"main.go":           "",
+		"tmp.go":            "",
+		"debug.log":         "",
+		"build/out.bin":     "",
+		".git/HEAD":         "",
+		"cmd/.gitignore":    "!keep.log\n",
+		"cmd/keep.log":      "",
+		"cmd/other.log":     "",
+		"cmd/main.go":       "",
+		"cmd/tmp.go":        "",
+		"vendor/lib/lib.go": "",
+	})
+	out := new(bytes.Buffer)
+	err := printPath(out, root, Options{
+		PrintFiles: true,
+		ShowHidden: true,
+		GitIgnore:  true,
+		Include:    []string{"*.go", "*.log", ".gitignore"},
+		Exclude:    []string{"vendor", "cmd/tmp.go"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := out.String(); result != testFilterResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testFilterResult)
+	}
+}
+
+func TestTreeFilterNegation(t *testing.T) {
+	// [!...] во всех шаблонах - отрицание, как в -find
+	root := makeTree(t, map[string]string{
+		".gitignore": "[!c]*.log\n",
+		"core.log":   "",
+		"debug.log":  "",
+		"main.go":    "",
+		"tmp.go":     "",
+		"Zed.go":     "",
+	})
+	out := new(bytes.Buffer)
+	err := printPath(out, root, Options{
+		PrintFiles: true,
+		GitIgnore:  true,
+		Include:    []string{"[!t]*"},
+		Exclude:    []string{"[!a-z]*"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "├───core.log (empty)\n└───main.go (empty)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+	if err := CheckPatterns([]string{"[!]"}); err == nil {
+		t.Errorf("expected error for bad pattern")
+	}
+}
+
+func TestTreeStream(t *testing.T) {
+	for _, printFiles := range []bool{true, false} {
+		expected, streamed := new(bytes.Buffer), new(bytes.Buffer)
+		if err := printPath(expected, testData, Options{PrintFiles: printFiles}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := printPath(streamed, testData, Options{PrintFiles: printFiles, Stream: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if streamed.String() != expected.String() {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", streamed, expected)
+		}
+	}
+}
+
+const testDepthResult = `├───project
+│	├───file.txt (19b)
+│	└───gopher.png (70372b)
+├───static
+│	├───a_lorem
+│	├───css
+│	├───empty.txt (empty)
+│	├───html
+│	├───js
+│	└───z_lorem
+├───zline
+│	├───empty.txt (empty)
+│	└───lorem
+└───zzfile.txt (empty)
+`
+
+func TestTreeDepth(t *testing.T) {
+	for _, stream := range []bool{true, false} {
+		out := new(bytes.Buffer)
+		err := printPath(out, testData, Options{PrintFiles: true, MaxDepth: 2, Stream: stream})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result := out.String(); result != testDepthResult {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testDepthResult)
+		}
+	}
+}
+
+func TestWalkTreeSkipDir(t *testing.T) {
+	paths := []string{}
+	err := WalkEntries(context.Background(), testData, Options{}, func(e *Entry) error {
+		paths = append(paths, e.Path)
+		if e.Node.Name == "static" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "project static zline zline/lorem zline/lorem/ipsum"
+	if result := strings.Join(paths, " "); result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+const testErrorsResult = `├───broken -> nowhere.go [broken symlink]
+├───closed [permission denied]
+└───file.txt (empty)
+`
+
+func TestTreeErrors(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not checked for root")
+	}
+	root := makeTree(t, map[string]string{
+		"file.txt":        "",
+		"closed/hide.txt": "",
+	})
+	if err := os.Symlink("nowhere.go", filepath.Join(root, "broken")); err != nil {
+		t.Fatal(err)
+	}
+	closed := filepath.Join(root, "closed")
+	if err := os.Chmod(closed, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(closed, 0755)
+
+	for _, stream := range []bool{true, false} {
+		out := new(bytes.Buffer)
+		err := printPath(out, root, Options{PrintFiles: true, Stream: stream})
+		if !errors.Is(err, errBrokenSymlink) || !errors.Is(err, os.ErrPermission) {
+			t.Errorf("expected aggregated error, got %v", err)
+		}
+		if result := out.String(); result != testErrorsResult {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testErrorsResult)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	err := printPath(out, root, Options{PrintFiles: true, Strict: true})
+	if !errors.Is(err, errBrokenSymlink) || errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected first error only, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output in strict mode, got:\n%v", out)
+	}
+}
+
+const testSymlinkResult = `└───a
+	├───file.txt (5b)
+	├───link.txt -> file.txt
+	└───loop -> ..
+`
+
+const testFollowResult = `└───a
+	├───file.txt (5b)
+	├───link.txt -> file.txt (5b)
+	└───loop -> .. [recursive, not followed]
+`
+
+func TestTreeSymlinks(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"a/file.txt": "hello",
+	})
+	if err := os.Symlink("file.txt", filepath.Join(root, "a", "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(root, "a", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stream := range []bool{true, false} {
+		for follow, expected := range map[bool]string{false: testSymlinkResult, true: testFollowResult} {
+			out := new(bytes.Buffer)
+			err := printPath(out, root, Options{PrintFiles: true, FollowSymlinks: follow, Stream: stream})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result := out.String(); result != expected {
+				t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+			}
+		}
+	}
+}
+
+func TestTreeParallel(t *testing.T) {
+	for _, printFiles := range []bool{true, false} {
+		expected, result := new(bytes.Buffer), new(bytes.Buffer)
+		if err := printPath(expected, testData, Options{PrintFiles: printFiles}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := printPath(result, testData, Options{PrintFiles: printFiles, Workers: 4}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.String() != expected.String() {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+}
+
+func benchmarkTree(b *testing.B) string {
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			for k := 0; k < 5; k++ {
+				files["d"+strconv.Itoa(i)+"/d"+strconv.Itoa(j)+"/f"+strconv.Itoa(k)] = "data"
+			}
+		}
+	}
+	return makeTree(b, files)
+}
+
+func benchmarkWalk(b *testing.B, workers int) {
+	root := benchmarkTree(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := printPath(io.Discard, root, Options{PrintFiles: true, Workers: workers})
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkWalkSequential(b *testing.B) {
+	benchmarkWalk(b, 0)
+}
+
+func BenchmarkWalkParallel(b *testing.B) {
+	benchmarkWalk(b, 8)
+}
+
+const testSizesResult = `├───project (68.7KiB, 2 files)
+├───static (275.0KiB, 10 files)
+│	├───a_lorem (137.4KiB, 3 files)
+│	│	└───ipsum (68.7KiB, 1 file)
+│	├───css (28B, 1 file)
+│	├───html (57B, 1 file)
+│	├───js (10B, 1 file)
+│	└───z_lorem (137.4KiB, 3 files)
+│		└───ipsum (68.7KiB, 1 file)
+└───zline (137.4KiB, 4 files)
+	└───lorem (137.4KiB, 3 files)
+		└───ipsum (68.7KiB, 1 file)
+`
+
+func TestTreeSizes(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printPath(out, testData, Options{DirSizes: true, FileCounts: true, Units: UnitsIEC})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := out.String(); result != testSizesResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testSizesResult)
+	}
+}
+
+// итоги считаются по всему каталогу, даже если глубже MaxDepth он не выводится
+const testSizesDepthResult = `├───project (68.7KiB, 2 files)
+├───static (275.0KiB, 10 files)
+└───zline (137.4KiB, 4 files)
+`
+
+func TestTreeSizesDepth(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		out := new(bytes.Buffer)
+		opts := Options{DirSizes: true, FileCounts: true, Units: UnitsIEC, MaxDepth: 1, Workers: workers}
+		stats, err := PrintFS(context.Background(), out, os.DirFS(testData), testData, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result := out.String(); result != testSizesDepthResult {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testSizesDepthResult)
+		}
+		if stats.Dirs != 3 {
+			t.Errorf("hidden directories must not be counted\nGot: %v\nExpected: %v", stats.Dirs, 3)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := []struct {
+		size     int64
+		units    Units
+		expected string
+	}{
+		{0, UnitsIEC, "empty"},
+		{1234, UnitsBytes, "1234b"},
+		{1023, UnitsIEC, "1023B"},
+		{1536, UnitsIEC, "1.5KiB"},
+		{1500, UnitsSI, "1.5kB"},
+		{5 << 30, UnitsIEC, "5.0GiB"},
+		{2500000, UnitsSI, "2.5MB"},
+	}
+	for _, c := range cases {
+		if result := formatSize(c.size, c.units); result != c.expected {
+			t.Errorf("formatSize(%d, %v): got %v, expected %v", c.size, c.units, result, c.expected)
+		}
+	}
+}
+
+const testSortResult = `└───static (275.0KiB)
+	├───a_lorem (137.4KiB)
+	│	├───gopher.png (68.7KiB)
+	│	├───ipsum (68.7KiB)
+	│	│	└───gopher.png (68.7KiB)
+	│	└───dolor.txt (empty)
+	├───z_lorem (137.4KiB)
+	│	├───gopher.png (68.7KiB)
+	│	├───ipsum (68.7KiB)
+	│	│	└───gopher.png (68.7KiB)
+	│	└───dolor.txt (empty)
+	├───html (57B)
+	│	└───index.html (57B)
+	├───css (28B)
+	│	└───body.css (28B)
+	├───js (10B)
+	│	└───site.js (10B)
+	└───empty.txt (empty)
+`
+
+func TestTreeSort(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printPath(out, testData, Options{
+		PrintFiles: true,
+		DirSizes:   true,
+		Units:      UnitsIEC,
+		Sort:       SortSize,
+		Exclude:    []string{"project", "zline", "zzfile.txt"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := out.String(); result != testSortResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testSortResult)
+	}
+
+	files := []*Node{
+		{Name: "b.txt"},
+		{Name: "a.go"},
+		{Name: "dir", IsDir: true},
+		{Name: "c.go"},
+	}
+	sortFiles(files, &Options{Sort: SortExt, DirsFirst: true, Reverse: true})
+	expected := "dir b.txt c.go a.go"
+	result := []string{}
+	for _, f := range files {
+		result = append(result, f.Name)
+	}
+	if strings.Join(result, " ") != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+func TestTreeStyles(t *testing.T) {
+	cases := map[Style]string{
+		StyleASCII:    "|-- gopher.png (70372b)\n`-- ipsum\n    `-- gopher.png (70372b)\n",
+		StyleUnicode:  "├── gopher.png (70372b)\n└── ipsum\n    └── gopher.png (70372b)\n",
+		StyleMarkdown: "- gopher.png (70372b)\n- ipsum\n  - gopher.png (70372b)\n",
+		StyleHTML: "<ul>\n  <li>gopher.png (70372b)</li>\n  <li>ipsum\n    <ul>\n" +
+			"      <li>gopher.png (70372b)</li>\n    </ul>\n  </li>\n</ul>\n",
+	}
+	for style, expected := range cases {
+		out := new(bytes.Buffer)
+		err := printPath(out, testData+"/zline/lorem", Options{PrintFiles: true, Exclude: []string{"*.txt"}, Style: style})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result := out.String(); result != expected {
+			t.Errorf("%v: results not match\nGot:\n%v\nExpected:\n%v", style, result, expected)
+		}
+	}
+}
+
+const testDiffResult = `├───[~] changed.txt (3b -> 5b)
+├───[+] new
+│	└───[+] file.txt (empty)
+├───[-] old
+│	└───[-] file.txt (empty)
+└───same.txt (empty)
+`
+
+func TestTreeDiff(t *testing.T) {
+	oldRoot := makeTree(t, map[string]string{
+		"same.txt":     "",
+		"changed.txt":  "old",
+		"old/file.txt": "",
+	})
+	newRoot := makeTree(t, map[string]string{
+		"same.txt":     "",
+		"changed.txt":  "newer",
+		"new/file.txt": "",
+	})
+	snapshot := filepath.Join(t.TempDir(), "old.json")
+	f, err := os.Create(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = printPath(f, oldRoot, Options{PrintFiles: true, Format: FormatJSON})
+	f.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, old := range []string{oldRoot, snapshot} {
+		out := new(bytes.Buffer)
+		stats, err := Diff(context.Background(), out, old, newRoot, Options{PrintFiles: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result := out.String(); result != testDiffResult {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testDiffResult)
+		}
+		if expected := (DiffStats{Added: 2, Removed: 2, Changed: 1}); stats != expected {
+			t.Errorf("bad stats: got %+v, expected %+v", stats, expected)
+		}
+	}
+
+	// в yaml и json отметки diff - отдельные поля
+	cases := []struct {
+		format   Format
+		expected []string
+	}{
+		{FormatYAML, []string{`diff: "changed"`, "old_size: 3", `diff: "added"`, `diff: "removed"`}},
+		{FormatJSON, []string{`"diff":"changed"`, `"old_size":3`, `"diff":"added"`, `"diff":"removed"`}},
+	}
+	for _, item := range cases {
+		out := new(bytes.Buffer)
+		_, err := Diff(context.Background(), out, oldRoot, newRoot, Options{PrintFiles: true, Format: item.format})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result := strings.ReplaceAll(out.String(), `": `, `":`)
+		for _, expected := range item.expected {
+			if !strings.Contains(result, expected) {
+				t.Errorf("format %v: no %s in output:\n%v", item.format, expected, out.String())
+			}
+		}
+	}
+}
+
+const testDuplicatesResult = `
+duplicate files:
+sha256:205b66874721, 70372b, 4 files:
+	a_lorem/gopher.png
+	a_lorem/ipsum/gopher.png
+	z_lorem/gopher.png
+	z_lorem/ipsum/gopher.png
+`
+
+func TestTreeDuplicates(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printPath(out, testData+"/static", Options{PrintFiles: true, Hash: true, Duplicates: true, Workers: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := out.String()
+	if !strings.Contains(result, "│	└───body.css (28b, sha256:05687b6da18d)\n") {
+		t.Errorf("no hash in listing:\n%v", result)
+	}
+	if !strings.HasSuffix(result, testDuplicatesResult) {
+		t.Errorf("results not match\nGot:\n%v\nExpected suffix:\n%v", result, testDuplicatesResult)
+	}
+}
+
+const testArchiveResult = `├───docs
+│	└───readme.md (5b)
+└───main.go (12b)
+`
+
+func TestTreeArchives(t *testing.T) {
+	files := []struct{ name, content string }{
+		{"docs/readme.md", "hello"},
+		{"main.go", "package tree"},
+	}
+	dir := t.TempDir()
+
+	zipName := filepath.Join(dir, "src.zip")
+	zf, err := os.Create(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.content))
+	}
+	zw.Close()
+	zf.Close()
+
+	tarName := filepath.Join(dir, "src.tar.gz")
+	tf, err := os.Create(tarName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(tf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		tw.WriteHeader(&tar.Header{Name: "./" + f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(f.content))
+	}
+	tw.Close()
+	gw.Close()
+	tf.Close()
+
+	for _, name := range []string{zipName, tarName} {
+		out := new(bytes.Buffer)
+		if err := printPath(out, name, Options{PrintFiles: true}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if result := out.String(); result != testArchiveResult {
+			t.Errorf("%s: results not match\nGot:\n%v\nExpected:\n%v", name, result, testArchiveResult)
+		}
+	}
+}
+
+const testMemFSResult = `└───a
+	├───file.txt (5b)
+	├───link.txt -> file.txt (5b)
+	└───loop -> .. [recursive, not followed]
+`
+
+func TestTreeMemFS(t *testing.T) {
+	fsys := newMemFS()
+	fsys.add("a/file.txt", 0644, time.Time{}, []byte("hello"), "")
+	fsys.add("a/link.txt", 0777, time.Time{}, nil, "file.txt")
+	fsys.add("a/loop", 0777, time.Time{}, nil, "..")
+	if err := fstest.TestFS(fsys, "a/file.txt", "a/link.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	_, err := PrintFS(context.Background(), out, fsys, "mem", Options{PrintFiles: true, FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := out.String(); result != testMemFSResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testMemFSResult)
+	}
+}
+
+func TestTreeWatchRefresh(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"uploads/a.bin": "aaa",
+		"uploads/b.bin": "bbb",
+		"keep.txt":      "",
+	})
+	tw, err := newTreeWatcher(context.Background(), root, Options{PrintFiles: true, DirSizes: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, "uploads", "a.bin"), []byte("aaaaaa"), 0644)
+	os.Remove(filepath.Join(root, "uploads", "b.bin"))
+	os.MkdirAll(filepath.Join(root, "uploads", "new"), 0755)
+	os.WriteFile(filepath.Join(root, "uploads", "new", "c.bin"), []byte("c"), 0644)
+
+	changes, err := tw.refresh("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := []string{}
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	expected := []string{
+		"changed uploads/a.bin (3b -> 6b)",
+		"removed uploads/b.bin",
+		"added uploads/new/",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", lines, expected)
+	}
+	if changes, _ := tw.refresh("uploads"); len(changes) != 0 {
+		t.Errorf("unexpected changes after refresh: %v", changes)
+	}
+
+	renderer, _ := newRenderer(&tw.w.opts)
+	out := new(bytes.Buffer)
+	renderer.Render(out, tw.root)
+	expectedTree := "├───keep.txt (empty)\n└───uploads (7b)\n\t├───a.bin (6b)\n\t└───new (1b)\n\t\t└───c.bin (1b)\n"
+	if result := out.String(); result != expectedTree {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expectedTree)
+	}
+}
+
+// syncBuffer - bytes.Buffer, который можно читать, пока в него пишет Watch
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitOutput ждёт, пока вывод станет expected, и возвращает последний увиденный
+func waitOutput(out *syncBuffer, expected string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for {
+		result := out.String()
+		if result == expected || time.Now().After(deadline) {
+			return result
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTreeWatch(t *testing.T) {
+	// Poll: 0 - inotify там, где он есть
+	for _, poll := range []time.Duration{0, 10 * time.Millisecond} {
+		root := makeTree(t, map[string]string{"a.txt": "a"})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		out := new(syncBuffer)
+		done := make(chan error)
+		go func() {
+			done <- Watch(ctx, out, root, Options{PrintFiles: true}, WatchOptions{Events: true, Poll: poll})
+		}()
+		initial := "└───a.txt (1b)\n"
+		if result := waitOutput(out, initial, time.Second); result != initial {
+			t.Fatalf("poll %v: results not match\nGot:\n%v\nExpected:\n%v", poll, result, initial)
+		}
+		os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644)
+		expected := initial + "added b.txt\n"
+		result := waitOutput(out, expected, 2*time.Second)
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("poll %v: unexpected error: %v", poll, err)
+		}
+		if result != expected {
+			t.Errorf("poll %v: results not match\nGot:\n%v\nExpected:\n%v", poll, result, expected)
+		}
+	}
+}
+
+// limitNotifier - inotify, у которого сразу кончились watch
+type limitNotifier struct {
+	events chan string
+	closed bool
+}
+
+func (n *limitNotifier) Events() <-chan string { return n.events }
+func (n *limitNotifier) Add(string) error      { return errWatchLimit }
+
+func (n *limitNotifier) Close() error {
+	n.closed = true
+	return nil
+}
+
+func TestTreeWatchFallback(t *testing.T) {
+	root := makeTree(t, map[string]string{"a.txt": "a"})
+	tw, err := newTreeWatcher(context.Background(), root, Options{PrintFiles: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := &limitNotifier{events: make(chan string)}
+	done := make(chan error)
+	go func() {
+		done <- tw.run(ctx, n, 10*time.Millisecond, func(changes []Change) error {
+			if len(changes) != 1 || changes[0].String() != "added b.txt" {
+				t.Errorf("unexpected changes: %v", changes)
+			}
+			cancel()
+			return nil
+		})
+	}()
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("change was not noticed after falling back to polling")
+	}
+	if !n.closed {
+		t.Errorf("inotify notifier must be closed after the fallback")
+	}
+}
+
+func TestTreeColumns(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"bin/run.sh": "#!/bin/sh\n",
+		"readme.txt": "hi",
+	})
+	mtime := time.Date(2021, time.March, 7, 9, 5, 0, 0, time.Local)
+	os.Chmod(filepath.Join(root, "bin"), 0750)
+	os.Chmod(filepath.Join(root, "bin", "run.sh"), 0755)
+	os.Chmod(filepath.Join(root, "readme.txt"), 0640)
+	for _, name := range []string{"bin/run.sh", "bin", "readme.txt"} {
+		os.Chtimes(filepath.Join(root, name), mtime, mtime)
+	}
+	me, err := user.Current()
+	if err != nil {
+		t.Skip("can't get current user:", err)
+	}
+	owner := fmt.Sprintf("%-8s", me.Username)
+
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{
+			Options{PrintFiles: true, Perms: true, ModTimes: true},
+			"├───[drwxr-x--- Mar  7 09:05]  bin\n" +
+				"│\t└───[-rwxr-xr-x Mar  7 09:05]  run.sh (10b)\n" +
+				"└───[-rw-r----- Mar  7 09:05]  readme.txt (2b)\n",
+		},
+		{
+			Options{PrintFiles: true, Owner: true, ModTimes: true, TimeFormat: ParseTimeFormat("iso")},
+			"├───[" + owner + " 2021-03-07 09:05]  bin\n" +
+				"│\t└───[" + owner + " 2021-03-07 09:05]  run.sh (10b)\n" +
+				"└───[" + owner + " 2021-03-07 09:05]  readme.txt (2b)\n",
+		},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		if err := printPath(out, root, c.opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result := out.String(); result != c.expected {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, c.expected)
+		}
+	}
+}
+
+const testFindResult = `├───project
+│	└───file.txt (19b)
+├───static
+│	├───a_lorem
+│	│	└───dolor.txt (empty)
+│	├───empty.txt (empty)
+│	└───z_lorem
+│		└───dolor.txt (empty)
+├───zline
+│	├───empty.txt (empty)
+│	└───lorem
+│		└───dolor.txt (empty)
+└───zzfile.txt (empty)
+`
+
+const testFindRegexResult = "├───static\n" +
+	"│\t├───a_\x1b[1;31mlorem\x1b[0m\n" +
+	"│\t└───z_\x1b[1;31mlorem\x1b[0m\n" +
+	"└───zline\n" +
+	"\t└───\x1b[1;31mlorem\x1b[0m\n"
+
+func TestGlobRegexp(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*.txt", "file.txt", true},
+		{"*.txt", "file.txt.bak", false},
+		{"[!g]*", "file.txt", true},
+		{"[!g]*", "gopher.png", false},
+		{"[^g]*", "gopher.png", false},
+		{"[a-c]?", "b1", true},
+		{"[\\]]x", "]x", true},
+		{"[\\]]x", "\\x", false},
+		{"[\\-]", "-", true},
+		{"[\\-]", "b", false},
+		{"[[]", "[", true},
+		{"[ж-я]*", "ёлка", false},
+		{"[ж-я]*", "ялта", true},
+		{"*", "a\nb", true},
+	}
+	for _, item := range cases {
+		re, err := GlobRegexp(item.pattern)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", item.pattern, err)
+			continue
+		}
+		if matched := re.MatchString(item.name); matched != item.matched {
+			t.Errorf("%q on %q: results not match\nGot: %v\nExpected: %v", item.pattern, item.name, matched, item.matched)
+		}
+	}
+	for _, pattern := range []string{"[a", "[!]", "[]", "a\\"} {
+		if _, err := GlobRegexp(pattern); err == nil {
+			t.Errorf("%q: expected error for bad class", pattern)
+		}
+	}
+}
+
+func TestTreeFind(t *testing.T) {
+	glob, err := GlobRegexp("*.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	stats, err := Print(context.Background(), out, testData, Options{PrintFiles: true, Find: glob})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := out.String(); result != testFindResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testFindResult)
+	}
+	if expected := (Stats{Dirs: 6, Files: 7}); stats != expected {
+		t.Errorf("stats not match\nGot:\n%v\nExpected:\n%v", stats, expected)
+	}
+
+	out.Reset()
+	opts := Options{Find: regexp.MustCompile(`lorem$`), Highlight: true, MaxDepth: 2}
+	if err := printPath(out, testData, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := out.String(); result != testFindRegexResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testFindRegexResult)
+	}
+}
+
+func TestTreeColors(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"bin/run.sh":   "",
+		"dist/app.tar": "",
+		"readme.txt":   "",
+	})
+	os.Chmod(filepath.Join(root, "bin", "run.sh"), 0755)
+	if err := os.Symlink("readme.txt", filepath.Join(root, "link")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	os.Symlink("missing", filepath.Join(root, "broken"))
+	os.Symlink("bin", filepath.Join(root, "linkdir"))
+
+	expected := "├───\x1b[34mbin\x1b[0m\n" +
+		"│\t└───\x1b[01;32mrun.sh\x1b[0m (empty)\n" +
+		"├───\x1b[40;31;01mbroken\x1b[0m -> missing [broken symlink]\n" +
+		"├───\x1b[34mdist\x1b[0m\n" +
+		"│\t└───\x1b[01;31mapp.tar\x1b[0m (empty)\n" +
+		"├───\x1b[01;36mlink\x1b[0m -> readme.txt\n" +
+		"├───\x1b[01;36mlinkdir\x1b[0m -> bin\n" +
+		"└───readme.txt (empty)\n"
+	out := new(bytes.Buffer)
+	opts := Options{PrintFiles: true, Colors: ParseLSColors("di=34:*.tar=01;31")}
+	printPath(out, root, opts)
+	if result := out.String(); result != expected {
+		t.Errorf("results not match\nGot:\n%q\nExpected:\n%q", result, expected)
+	}
+
+	// ln=target красит симлинк как цель, нечисловые коды пропускаются
+	expected = "├───\x1b[01;34mbin\x1b[0m\n" +
+		"│\t└───\x1b[01;32mrun.sh\x1b[0m (empty)\n" +
+		"├───\x1b[40;31;01mbroken\x1b[0m -> missing [broken symlink]\n" +
+		"├───\x1b[01;34mdist\x1b[0m\n" +
+		"│\t└───app.tar (empty)\n" +
+		"├───link -> readme.txt\n" +
+		"├───\x1b[01;34mlinkdir\x1b[0m -> bin\n" +
+		"└───readme.txt (empty)\n"
+	out.Reset()
+	opts.Colors = ParseLSColors("ln=target:di=bogus:*.tar=x")
+	printPath(out, root, opts)
+	if result := out.String(); result != expected {
+		t.Errorf("results not match\nGot:\n%q\nExpected:\n%q", result, expected)
+	}
+}
+
+func TestWalkRender(t *testing.T) {
+	root, err := Walk(context.Background(), testData+"/zline", Options{PrintFiles: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := new(bytes.Buffer)
+	if err := Render(out, root, StyleASCII); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "|-- empty.txt (empty)\n`-- lorem\n    |-- dolor.txt (empty)\n    |-- gopher.png (70372b)\n    `-- ipsum\n        `-- gopher.png (70372b)\n"
+	if result := out.String(); result != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{0, 4} {
+		if _, err := Walk(ctx, testData, Options{Workers: workers}); !errors.Is(err, context.Canceled) {
+			t.Errorf("workers %d: expected context.Canceled, got %v", workers, err)
+		}
+	}
+	err = WalkEntries(ctx, testData, Options{}, func(e *Entry) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTreeHidden(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		".env":             "SECRET=1",
+		".config/app.yml":  "",
+		"docs/.draft.md":   "",
+		"docs/index.md":    "",
+		"docs/usage.md":    "",
+		"src/main.go":      "",
+		"src/.cache/a.bin": "",
+	})
+	cases := []struct {
+		opts     Options
+		expected string
+	}{
+		{
+			Options{PrintFiles: true},
+			"├───docs\n│\t├───index.md (empty)\n│\t└───usage.md (empty)\n└───src\n\t└───main.go (empty)\n",
+		},
+		{
+			Options{FileCounts: true},
+			"├───docs (2 files)\n└───src (1 file)\n",
+		},
+		{
+			Options{FileCounts: true, ShowHidden: true},
+			"├───.config (1 file)\n├───docs (3 files)\n└───src (2 files)\n\t└───.cache (1 file)\n",
+		},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		if err := printPath(out, root, c.opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result := out.String(); result != c.expected {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, c.expected)
+		}
+	}
+}
+
+const testManifestResult = `# tree manifest v1
+d 755 "css"
+f 644 6 "css/body.css" Ym9keXt9
+f 600 0 "empty.txt"
+l "link" "empty.txt"
+`
+
+func TestManifest(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"css/body.css": "body{}",
+		"empty.txt":    "",
+	})
+	os.Chmod(filepath.Join(root, "css"), 0755)
+	os.Chmod(filepath.Join(root, "css", "body.css"), 0644)
+	os.Chmod(filepath.Join(root, "empty.txt"), 0600)
+	if err := os.Symlink("empty.txt", filepath.Join(root, "link")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	manifest := new(bytes.Buffer)
+	if err := WriteManifest(context.Background(), manifest, root, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := manifest.String(); result != testManifestResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testManifestResult)
+	}
+
+	manifest.Reset()
+	ctx := context.Background()
+	if err := WriteManifest(ctx, manifest, testData, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "testdata.manifest")
+	if err := os.WriteFile(name, manifest.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected, result := new(bytes.Buffer), new(bytes.Buffer)
+	printPath(expected, testData, Options{PrintFiles: true})
+	if err := printPath(result, name, Options{PrintFiles: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.String() != expected.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	dir := filepath.Join(t.TempDir(), "fixture")
+	if err := Materialize(bytes.NewReader(manifest.Bytes()), dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats, err := Diff(ctx, io.Discard, testData, dir, Options{PrintFiles: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats != (DiffStats{}) {
+		t.Errorf("materialized tree differs from testdata: %v", stats)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "project", "file.txt"))
+	if original, _ := os.ReadFile(testData + "/project/file.txt"); err != nil || !bytes.Equal(data, original) {
+		t.Errorf("content not match\nGot:\n%s\nExpected:\n%s", data, original)
+	}
+
+	// большие файлы сохранены только размером и sha256, -check видит, что в них нули
+	stats, err = CheckManifest(ctx, io.Discard, bytes.NewReader(manifest.Bytes()), testData, Options{})
+	if err != nil || stats != (DiffStats{}) {
+		t.Errorf("testdata must match its own manifest: %v, %v", stats, err)
+	}
+	stats, err = CheckManifest(ctx, io.Discard, bytes.NewReader(manifest.Bytes()), dir, Options{})
+	if err != nil || stats.Changed == 0 || stats.Added != 0 || stats.Removed != 0 {
+		t.Errorf("zero-filled files must be reported as changed: %v, %v", stats, err)
+	}
+
+	manifest.Reset()
+	if err := WriteManifest(ctx, manifest, testData, Options{InlineSize: 1 << 20}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir = filepath.Join(t.TempDir(), "inline")
+	if err := Materialize(bytes.NewReader(manifest.Bytes()), dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats, err = CheckManifest(ctx, io.Discard, bytes.NewReader(manifest.Bytes()), dir, Options{})
+	if err != nil || stats != (DiffStats{}) {
+		t.Errorf("fixture with inline content must match: %v, %v", stats, err)
+	}
+}
+
+func TestManifestLines(t *testing.T) {
+	// размер без содержимого не выделяет память под файл
+	fsys, err := ReadManifest(strings.NewReader("f 644 9000000000000000000 \"big.bin\"\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := fs.Stat(fsys, "big.bin"); err != nil || info.Size() != 9000000000000000000 {
+		t.Errorf("unexpected size: %v, %v", info, err)
+	}
+
+	bad := []string{
+		"f 644 3 \"a.txt\" aGVsbG8=\n",
+		"f 644 9000000000000000000 \"a.txt\" aGVsbG8=\n",
+		"f 644 5 \"a.txt\" sha256:abc\n",
+		"f 644 99999999999999999999 \"a.txt\"\n",
+	}
+	for _, line := range bad {
+		if _, err := ReadManifest(strings.NewReader(line)); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package tree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+const watchDebounce = 100 * time.Millisecond
+
+// watchFallbackPoll - интервал опроса, когда inotify недоступен или кончились watch
+const watchFallbackPoll = time.Second
+
+// errWatchLimit - inotify упёрся в fs.inotify.max_user_watches
+var errWatchLimit = errors.New("inotify watch limit reached")
+
+type WatchOptions struct {
+	// Events - печатать изменения вместо перерисовки дерева
+	Events bool
+	// Poll - опрашивать дерево с этим интервалом вместо inotify
+	Poll time.Duration
+	// Clear - очищать экран перед перерисовкой
+	Clear bool
+}
+
+type Change struct {
+	Kind    DiffStatus
+	Path    string
+	IsDir   bool
+	Size    int64
+	OldSize int64
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case DiffChanged:
+		return fmt.Sprintf("changed %s (%db -> %db)", c.Path, c.OldSize, c.Size)
+	case DiffAdded, DiffRemoved:
+		if c.IsDir {
+			return diffNames[c.Kind] + " " + c.Path + "/"
+		}
+		return diffNames[c.Kind] + " " + c.Path
+	}
+	return c.Path
+}
+
+// notifier сообщает относительные пути каталогов, в которых что-то поменялось
+type notifier interface {
+	Events() <-chan string
+	Add(rel string) error
+	Close() error
+}
+
+type pollNotifier struct {
+	events chan string
+	stop   chan struct{}
+}
+
+func newPollNotifier(interval time.Duration) *pollNotifier {
+	n := &pollNotifier{events: make(chan string), stop: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer close(n.events)
+		for {
+			select {
+			case <-n.stop:
+				return
+			case <-ticker.C:
+			}
+			select {
+			case <-n.stop:
+				return
+			case n.events <- "":
+			}
+		}
+	}()
+	return n
+}
+
+func (n *pollNotifier) Events() <-chan string { return n.events }
+func (n *pollNotifier) Add(string) error      { return nil }
+
+func (n *pollNotifier) Close() error {
+	close(n.stop)
+	return nil
+}
+
+type treeWatcher struct {
+	w    *walker
+	root *Node
+}
+
+func newTreeWatcher(ctx context.Context, path string, opts Options) (*treeWatcher, error) {
+	if err := CheckPatterns(opts.Include, opts.Exclude); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("can't watch %s: not a directory", path)
+	}
+	w := newWalker(ctx, os.DirFS(path), opts)
+	root, err := w.buildRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	return &treeWatcher{w: w, root: root}, nil
+}
+
+// locate находит узел каталога rel и восстанавливает для него level с правилами .gitignore
+func (t *treeWatcher) locate(rel string) ([]*Node, *level, error) {
+	node, l := t.root, rootLevel(t.root)
+	path := []*Node{node}
+	if rel == "" {
+		return path, l, nil
+	}
+	for _, name := range strings.Split(rel, "/") {
+		if t.w.opts.GitIgnore {
+			ignores, err := loadIgnoreRules(t.w.fsys, l.path, l.rel, l.ignores)
+			if err != nil {
+				return nil, nil, err
+			}
+			l.ignores = ignores
+		}
+		var next *Node
+		if node.Children != nil {
+			for _, child := range node.Children {
+				if child.Name == name {
+					next = child
+					break
+				}
+			}
+		}
+		if next == nil || !next.IsDir {
+			return nil, nil, fs.ErrNotExist
+		}
+		node, l = next, l.child(next)
+		path = append(path, node)
+	}
+	return path, l, nil
+}
+
+// refresh перечитывает каталог rel и возвращает изменения относительно дерева в памяти
+func (t *treeWatcher) refresh(rel string) ([]Change, error) {
+	t.w.errs = nil
+	path, l, err := t.locate(rel)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	node := path[len(path)-1]
+
+	fresh := *node
+	fresh.Children, fresh.Err, fresh.TotalSize, fresh.Files = []*Node{}, nil, 0, 0
+	if err := t.w.walkDir(&fresh, l); err != nil {
+		return nil, err
+	}
+	if errors.Is(fresh.Err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if t.w.opts.Find != nil {
+		t.w.prune(&fresh)
+	}
+
+	merged := diffDir(node, &fresh, &t.w.opts, &DiffStats{})
+	changes := []Change{}
+	collectChanges(merged, rel, &changes)
+	clearDiff(&fresh)
+
+	if t.w.opts.DirSizes || t.w.opts.FileCounts {
+		aggregate(&fresh)
+		for _, parent := range path[:len(path)-1] {
+			parent.TotalSize += fresh.TotalSize - node.TotalSize
+			parent.Files += fresh.Files - node.Files
+		}
+	}
+	*node = fresh
+	if (t.w.opts.DirSizes || t.w.opts.FileCounts) && t.w.opts.Sort == SortSize {
+		sortTree(node, &t.w.opts)
+		for _, parent := range path[:len(path)-1] {
+			sortFiles(parent.Children, &t.w.opts)
+		}
+	}
+	return changes, nil
+}
+
+func collectChanges(dir *Node, rel string, changes *[]Change) {
+	for _, child := range dir.Children {
+		path := joinRel(rel, child.Name)
+		if child.Diff != DiffNone {
+			*changes = append(*changes, Change{
+				Kind:    child.Diff,
+				Path:    path,
+				IsDir:   child.IsDir,
+				Size:    child.Size,
+				OldSize: child.OldSize,
+			})
+			if child.Diff != DiffChanged {
+				continue
+			}
+		}
+		if child.IsDir {
+			collectChanges(child, path, changes)
+		}
+	}
+}
+
+func clearDiff(dir *Node) {
+	for _, child := range dir.Children {
+		child.Diff, child.OldSize = DiffNone, 0
+		if child.IsDir {
+			clearDiff(child)
+		}
+	}
+}
+
+func (t *treeWatcher) watchDirs(n notifier, dir *Node, rel string) error {
+	if err := n.Add(rel); err != nil {
+		return err
+	}
+	for _, child := range dir.Children {
+		if child.IsDir && child.Link == "" && child.Err == nil {
+			if err := t.watchDirs(n, child, joinRel(rel, child.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run ждёт уведомлений, схлопывает пачку за watchDebounce и обновляет только верхние из каталогов.
+// Если watch на все каталоги не хватает, n закрывается и дерево опрашивается раз в fallback
+func (t *treeWatcher) run(ctx context.Context, n notifier, fallback time.Duration, onChange func([]Change) error) error {
+	defer func() { n.Close() }()
+	watch := func(dir *Node, rel string) error {
+		err := t.watchDirs(n, dir, rel)
+		if !errors.Is(err, errWatchLimit) {
+			return err
+		}
+		n.Close()
+		n = newPollNotifier(fallback)
+		return nil
+	}
+	if err := watch(t.root, ""); err != nil {
+		return err
+	}
+	for {
+		dirs := map[string]bool{}
+		select {
+		case <-ctx.Done():
+			return nil
+		case rel, ok := <-n.Events():
+			if !ok {
+				return nil
+			}
+			dirs[rel] = true
+		}
+		timer := time.NewTimer(watchDebounce)
+	collect:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case rel, ok := <-n.Events():
+				if !ok {
+					break collect
+				}
+				dirs[rel] = true
+			case <-timer.C:
+				break collect
+			}
+		}
+
+		changes := []Change{}
+		for rel := range dirs {
+			if coveredBy(rel, dirs) {
+				continue
+			}
+			dirChanges, err := t.refresh(rel)
+			if err != nil {
+				// обход прервала отмена ctx - это обычное завершение
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			changes = append(changes, dirChanges...)
+			for _, change := range dirChanges {
+				if change.Kind == DiffAdded && change.IsDir {
+					if path, _, err := t.locate(change.Path); err == nil {
+						if err := watch(path[len(path)-1], change.Path); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := onChange(changes); err != nil {
+			return err
+		}
+	}
+}
+
+func coveredBy(rel string, dirs map[string]bool) bool {
+	for dir := range dirs {
+		if dir != rel && (dir == "" || strings.HasPrefix(rel, dir+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch выводит дерево и обновляет его при изменениях, пока не отменён ctx
+func Watch(ctx context.Context, out io.Writer, path string, opts Options, wopts WatchOptions) error {
+	t, err := newTreeWatcher(ctx, path, opts)
+	if err != nil {
+		return err
+	}
+	renderer, err := newRenderer(&opts)
+	if err != nil {
+		return err
+	}
+	redraw := func() error {
+		if wopts.Clear {
+			if _, err := io.WriteString(out, "\x1b[H\x1b[2J"); err != nil {
+				return err
+			}
+		}
+		return renderer.Render(out, t.root)
+	}
+	if err := redraw(); err != nil {
+		return err
+	}
+
+	var n notifier
+	if wopts.Poll <= 0 {
+		if n, err = newInotify(path); err != nil {
+			wopts.Poll = watchFallbackPoll
+		}
+	}
+	if n == nil {
+		n = newPollNotifier(wopts.Poll)
+	}
+
+	return t.run(ctx, n, watchFallbackPoll, func(changes []Change) error {
+		if !wopts.Events {
+			if !wopts.Clear {
+				if _, err := io.WriteString(out, "\n"); err != nil {
+					return err
+				}
+			}
+			return redraw()
+		}
+		for _, change := range changes {
+			if _, err := fmt.Fprintln(out, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
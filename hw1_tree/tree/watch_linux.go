@@ -0,0 +1,120 @@
+//go:build linux
+
+package tree
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB |
+	syscall.IN_CLOSE_WRITE | syscall.IN_DELETE_SELF
+
+// inotify держит по одному watch на каталог, wd переводится обратно в относительный путь
+type inotify struct {
+	fd     int
+	file   *os.File
+	root   string
+	events chan string
+	done   chan struct{}
+
+	mu    sync.Mutex
+	paths map[int32]string
+}
+
+func newInotify(root string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	n := &inotify{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		root:   root,
+		events: make(chan string),
+		done:   make(chan struct{}),
+		paths:  map[int32]string{},
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) Events() <-chan string { return n.events }
+
+func (n *inotify) Add(rel string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, filepath.Join(n.root, filepath.FromSlash(rel)), inotifyMask)
+	if err != nil {
+		// каталог могли удалить до того, как мы до него добрались
+		if err == syscall.ENOENT || err == syscall.ENOTDIR {
+			return nil
+		}
+		if err == syscall.ENOSPC {
+			return errWatchLimit
+		}
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	n.mu.Lock()
+	n.paths[int32(wd)] = rel
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *inotify) Close() error {
+	close(n.done)
+	return n.file.Close()
+}
+
+func (n *inotify) read() {
+	defer close(n.events)
+	buf := make([]byte, 64*1024)
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= size; {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			offset += syscall.SizeofInotifyEvent + nameLen
+
+			rel, ok := n.eventPath(wd, mask)
+			if !ok {
+				continue
+			}
+			select {
+			case n.events <- rel:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
+
+// eventPath переводит событие в каталог, который надо перечитать
+func (n *inotify) eventPath(wd int32, mask uint32) (string, bool) {
+	// очередь ядра переполнилась и часть событий потеряна: перечитываем всё дерево
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		return "", true
+	}
+	n.mu.Lock()
+	rel, ok := n.paths[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(n.paths, wd)
+	}
+	n.mu.Unlock()
+	if !ok || mask&syscall.IN_IGNORED != 0 {
+		return "", false
+	}
+	if mask&syscall.IN_DELETE_SELF != 0 {
+		rel = filepath.ToSlash(filepath.Dir(filepath.FromSlash(rel)))
+		if rel == "." {
+			rel = ""
+		}
+	}
+	return rel, true
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package tree
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestInotifyEventPath(t *testing.T) {
+	n := &inotify{paths: map[int32]string{1: "", 2: "uploads", 3: "uploads/new"}}
+	cases := []struct {
+		wd   int32
+		mask uint32
+		rel  string
+		ok   bool
+	}{
+		{2, syscall.IN_CREATE, "uploads", true},
+		{3, syscall.IN_DELETE_SELF, "uploads", true},
+		{2, syscall.IN_DELETE_SELF, "", true},
+		{-1, syscall.IN_Q_OVERFLOW, "", true},
+		{4, syscall.IN_CREATE, "", false},
+		{3, syscall.IN_IGNORED, "", false},
+		{3, syscall.IN_CREATE, "", false},
+	}
+	for _, item := range cases {
+		rel, ok := n.eventPath(item.wd, item.mask)
+		if rel != item.rel || ok != item.ok {
+			t.Errorf("wd %d, mask %#x: results not match\nGot: %q %v\nExpected: %q %v", item.wd, item.mask, rel, ok, item.rel, item.ok)
+		}
+	}
+}
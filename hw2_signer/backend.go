@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"strconv"
+	"sync"
+)
+
+// Signer - способ подписи данных для Hasher
+type Signer interface {
+	Sign(ctx context.Context, data string) (string, error)
+}
+
+// SignerFunc позволяет использовать обычную функцию как Signer
+type SignerFunc func(ctx context.Context, data string) (string, error)
+
+func (f SignerFunc) Sign(ctx context.Context, data string) (string, error) {
+	return f(ctx, data)
+}
+
+// md5Mutex - DataSignerMd5 перегревается от одновременных вызовов, поэтому
+// вызовы сериализуются для всех конвейеров сразу
+var md5Mutex sync.Mutex
+
+var (
+	// DataMd5Signer вызывает DataSignerMd5 из common.go по одному за раз
+	DataMd5Signer = SignerFunc(func(ctx context.Context, data string) (string, error) {
+		md5Mutex.Lock()
+		defer md5Mutex.Unlock()
+		return DataSignerMd5(data), nil
+	})
+	// DataCrc32Signer вызывает DataSignerCrc32 из common.go
+	DataCrc32Signer = SignerFunc(func(ctx context.Context, data string) (string, error) {
+		return DataSignerCrc32(data), nil
+	})
+)
+
+// MD5Signer - md5 в hex, как DataSignerMd5, но без искусственных задержек
+type MD5Signer struct {
+	Salt string
+}
+
+func (s MD5Signer) Sign(ctx context.Context, data string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	sum := md5.Sum([]byte(data + s.Salt))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CRC32Signer - crc32 IEEE в десятичной записи, как DataSignerCrc32, но без задержек
+type CRC32Signer struct {
+	Salt string
+}
+
+func (s CRC32Signer) Sign(ctx context.Context, data string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(data+s.Salt))), 10), nil
+}
+
+// SHA256Signer - sha256 в hex
+type SHA256Signer struct {
+	Salt string
+}
+
+func (s SHA256Signer) Sign(ctx context.Context, data string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(data + s.Salt))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HMACSigner - hmac с ключом Key в hex, Hash по умолчанию sha256
+type HMACSigner struct {
+	Key  []byte
+	Hash func() hash.Hash
+}
+
+func (s HMACSigner) Sign(ctx context.Context, data string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	newHash := s.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	mac := hmac.New(newHash, s.Key)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignerBackends(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		signer   Signer
+		data     string
+		expected string
+	}{
+		{MD5Signer{}, "0", "cfcd208495d565ef66e7dff9f98764da"},
+		{CRC32Signer{}, "0", "4108050209"},
+		{SHA256Signer{}, "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{SHA256Signer{Salt: "c"}, "ab", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{HMACSigner{Key: []byte("key")}, "The quick brown fox jumps over the lazy dog", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{HMACSigner{Key: []byte("key"), Hash: sha1.New}, "The quick brown fox jumps over the lazy dog", "de7c9b85b8b78aa6bc8a7a36f70a90701c9db4d9"},
+	}
+	for _, item := range cases {
+		result, err := item.signer.Sign(ctx, item.data)
+		if err != nil {
+			t.Errorf("%T: unexpected error: %v", item.signer, err)
+			continue
+		}
+		if result != item.expected {
+			t.Errorf("%T: results not match\nGot: %v\nExpected: %v", item.signer, result, item.expected)
+		}
+	}
+}
+
+func TestHasherBackends(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	testResult := "NOT_SET"
+
+	// конвейер на своих подписях не должен трогать глобальные
+	origMd5, origCrc32 := DataSignerMd5, DataSignerCrc32
+	defer func() { DataSignerMd5, DataSignerCrc32 = origMd5, origCrc32 }()
+	DataSignerMd5 = func(data string) string {
+		t.Errorf("DataSignerMd5 called")
+		return ""
+	}
+	DataSignerCrc32 = func(data string) string {
+		t.Errorf("DataSignerCrc32 called")
+		return ""
+	}
+
+	hasher := Hasher{Digest: MD5Signer{}, Checksum: CRC32Signer{}}
+	start := time.Now()
+	err := ExecutePipelineContext(context.Background(),
+		withContext(func(in, out chan interface{}) {
+			out <- 0
+			out <- 1
+		}),
+		hasher.SingleHash(),
+		hasher.MultiHash(),
+		withContext(CombineResults),
+		withContext(func(in, out chan interface{}) {
+			testResult = (<-in).(string)
+		}),
+	)
+	end := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if testResult != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", testResult, testExpected)
+	}
+	if expectedTime := 100 * time.Millisecond; end > expectedTime {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, expectedTime)
+	}
+}
+
+func TestHasherError(t *testing.T) {
+	errBad := errors.New("bad value")
+	failing := SignerFunc(func(ctx context.Context, data string) (string, error) {
+		if data == "3" {
+			return "", errBad
+		}
+		return data, nil
+	})
+
+	for _, ordered := range []bool{false, true} {
+		hasher := Hasher{Digest: failing, Checksum: failing, Workers: 2, Ordered: ordered}
+		err := ExecutePipelineContext(context.Background(),
+			// бесконечный источник, остановить его может только ошибка
+			func(ctx context.Context, in, out chan interface{}) error {
+				for i := 0; ; i++ {
+					if err := send(ctx, out, i); err != nil {
+						return err
+					}
+				}
+			},
+			hasher.SingleHash(),
+			hasher.MultiHash(),
+		)
+		if !errors.Is(err, errBad) {
+			t.Errorf("ordered %v: expected %v, got %v", ordered, errBad, err)
+		}
+	}
+}
+
+func TestHasherTypeError(t *testing.T) {
+	hasher := Hasher{Digest: MD5Signer{}, Checksum: CRC32Signer{}}
+	cases := []struct {
+		stage    ctxJob
+		val      interface{}
+		expected string
+	}{
+		{hasher.SingleHash(), 1.5, "SingleHash: unsupported value float64, expected int or string"},
+		{hasher.MultiHash(), 1, "MultiHash: unsupported value int, expected string"},
+	}
+	for _, item := range cases {
+		err := ExecutePipelineContext(context.Background(),
+			withContext(func(in, out chan interface{}) {
+				out <- item.val
+			}),
+			item.stage,
+		)
+		if err == nil || err.Error() != item.expected {
+			t.Errorf("expected %q, got %v", item.expected, err)
+		}
+	}
+}
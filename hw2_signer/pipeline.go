@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ctxJob - звено конвейера, которое умеет останавливаться по ctx и сообщать об ошибке
+type ctxJob func(ctx context.Context, in, out chan interface{}) error
+
+// errPipelineDone - причина отмены ctx, когда последнее звено отработало без ошибок
+var errPipelineDone = errors.New("pipeline done")
+
+// ExecutePipelineContext запускает звенья конвейера. Первая ошибка любого звена
+// отменяет ctx всех остальных и возвращается из функции
+func ExecutePipelineContext(ctx context.Context, jobs ...ctxJob) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	in := make(chan interface{})
+	close(in)
+
+	for i, j := range jobs {
+		out := make(chan interface{})
+		wg.Add(1)
+		go func(task ctxJob, in, out chan interface{}, last bool) {
+			defer wg.Done()
+			// причину отмены запоминаем до close(out), иначе следующее звено успеет
+			// завершить конвейер как успешный
+			err := task(ctx, in, out)
+			switch {
+			case err != nil:
+				cancel(err)
+			case last:
+				cancel(errPipelineDone)
+			}
+			close(out)
+			// вычитываем остаток, чтобы предыдущее звено не зависло на записи
+			for range in {
+			}
+		}(j, in, out, i == len(jobs)-1)
+		in = out
+	}
+
+	wg.Add(1)
+	go func(in chan interface{}) {
+		defer wg.Done()
+		for range in {
+		}
+	}(in)
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != errPipelineDone {
+		return err
+	}
+	return nil
+}
+
+// withContext превращает обычный job в звено ExecutePipelineContext
+func withContext(j job) ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		j(in, out)
+		return nil
+	}
+}
+
+// withoutContext превращает звено ExecutePipelineContext в обычный job.
+// Вернуть ошибку job не может, поэтому паникует, а не обрывает поток молча
+func withoutContext(j ctxJob) job {
+	return func(in, out chan interface{}) {
+		if err := j(context.Background(), in, out); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// send пишет val в out, пока конвейер не остановлен
+func send(ctx context.Context, out chan interface{}, val interface{}) error {
+	select {
+	case out <- val:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipelineContextError(t *testing.T) {
+	errBad := errors.New("bad value")
+	var stopped uint32
+
+	start := time.Now()
+	err := ExecutePipelineContext(context.Background(),
+		// бесконечный источник, остановить его может только отмена ctx
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 0; ; i++ {
+				if err := send(ctx, out, i); err != nil {
+					atomic.AddUint32(&stopped, 1)
+					return err
+				}
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				if val.(int) == 3 {
+					return errBad
+				}
+				if err := send(ctx, out, val); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// звено, которое без ctx зависло бы навсегда после закрытия in
+		func(ctx context.Context, in, out chan interface{}) error {
+			for range in {
+			}
+			<-ctx.Done()
+			atomic.AddUint32(&stopped, 1)
+			return ctx.Err()
+		},
+	)
+	if !errors.Is(err, errBad) {
+		t.Errorf("expected %v, got %v", errBad, err)
+	}
+	if stopped != 2 {
+		t.Errorf("upstream and downstream stages must be stopped, stopped = %d", stopped)
+	}
+	if end := time.Since(start); end > time.Second {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, time.Second)
+	}
+}
+
+func TestPipelineContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := ExecutePipelineContext(ctx,
+		withContext(func(in, out chan interface{}) {
+			out <- 1
+		}),
+		func(ctx context.Context, in, out chan interface{}) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestPipelineContextDone(t *testing.T) {
+	var recieved uint32
+	err := ExecutePipelineContext(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 1; ; i++ {
+				if err := send(ctx, out, i); err != nil {
+					return err
+				}
+			}
+		},
+		// последнее звено забирает одно значение и завершает весь конвейер без ошибки
+		func(ctx context.Context, in, out chan interface{}) error {
+			atomic.AddUint32(&recieved, uint32((<-in).(int)))
+			return nil
+		},
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if recieved != 1 {
+		t.Errorf("expected the first value, recieved = %d", recieved)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// poolFunc - обработка одного значения в runPool
+type poolFunc func(ctx context.Context, val interface{}) (interface{}, error)
+
+// runPool обрабатывает значения из in в workers горутинах и пишет результаты fn в out.
+// С ordered результаты идут в порядке входа, иначе в порядке готовности.
+// Первая ошибка fn останавливает всех и возвращается
+func runPool(ctx context.Context, workers int, ordered bool, in, out chan interface{}, fn poolFunc) error {
+	if workers < 1 {
+		workers = HashWorkers
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if ordered {
+		runOrdered(ctx, cancel, workers, in, out, fn)
+	} else {
+		runUnordered(ctx, cancel, workers, in, out, fn)
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+func runUnordered(ctx context.Context, cancel context.CancelCauseFunc, workers int, in, out chan interface{}, fn poolFunc) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var val interface{}
+				var ok bool
+				select {
+				case val, ok = <-in:
+				case <-ctx.Done():
+					return
+				}
+				if !ok {
+					return
+				}
+				res, err := fn(ctx, val)
+				if err != nil {
+					cancel(err)
+					return
+				}
+				if send(ctx, out, res) != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// seqItem - значение с номером его позиции во входном потоке
+type seqItem struct {
+	seq int
+	val interface{}
+}
+
+// runOrdered нумерует входные значения, а результаты, обогнавшие предыдущие,
+// держит в буфере до их очереди. В обработке и в буфере вместе не больше workers
+// значений, так что медленное значение не даёт буферу расти
+func runOrdered(ctx context.Context, cancel context.CancelCauseFunc, workers int, in, out chan interface{}, fn poolFunc) {
+	tasks := make(chan seqItem)
+	results := make(chan seqItem)
+	slots := make(chan struct{}, workers)
+
+	go func() {
+		defer close(tasks)
+		for seq := 0; ; seq++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			var val interface{}
+			var ok bool
+			select {
+			case val, ok = <-in:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+			select {
+			case tasks <- seqItem{seq, val}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				res, err := fn(ctx, task.val)
+				if err != nil {
+					cancel(err)
+					return
+				}
+				select {
+				case results <- seqItem{task.seq, res}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// results читаем до закрытия и после ошибки, чтобы дождаться всех горутин
+	pending := map[int]interface{}{}
+	next := 0
+	for res := range results {
+		pending[res.seq] = res.val
+		for ctx.Err() == nil {
+			val, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if send(ctx, out, val) != nil {
+				break
+			}
+			<-slots
+			next++
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countCrc32 подменяет DataSignerCrc32 на быструю версию, которая считает
+// максимальное число одновременных вызовов
+func countCrc32(t *testing.T) *int32 {
+	var active, peak int32
+	origCrc32 := DataSignerCrc32
+	t.Cleanup(func() { DataSignerCrc32 = origCrc32 })
+	DataSignerCrc32 = func(data string) string {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return data
+	}
+	return &peak
+}
+
+func TestSingleHashPool(t *testing.T) {
+	peak := countCrc32(t)
+	origMd5 := DataSignerMd5
+	defer func() { DataSignerMd5 = origMd5 }()
+	DataSignerMd5 = func(data string) string { return data }
+
+	var recieved int
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			for i := 0; i < 50; i++ {
+				out <- i
+			}
+		}),
+		SingleHashPool(2),
+		job(func(in, out chan interface{}) {
+			for range in {
+				recieved++
+			}
+		}),
+	)
+	if recieved != 50 {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", recieved, 50)
+	}
+	// на каждое значение два DataSignerCrc32
+	if *peak > 2*2 {
+		t.Errorf("too many concurrent crc32 calls\nGot: %v\nExpected: <=%v", *peak, 2*2)
+	}
+}
+
+func TestMultiHashPool(t *testing.T) {
+	peak := countCrc32(t)
+
+	var recieved int
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			for i := 0; i < 50; i++ {
+				out <- "data"
+			}
+		}),
+		MultiHashPool(3),
+		job(func(in, out chan interface{}) {
+			for range in {
+				recieved++
+			}
+		}),
+	)
+	if recieved != 50 {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", recieved, 50)
+	}
+	if *peak > 3*TH {
+		t.Errorf("too many concurrent crc32 calls\nGot: %v\nExpected: <=%v", *peak, 3*TH)
+	}
+}
+
+func TestMultiHashOrdered(t *testing.T) {
+	origCrc32 := DataSignerCrc32
+	defer func() { DataSignerCrc32 = origCrc32 }()
+	// чем раньше значение, тем дольше оно считается, без буфера порядок бы развернулся
+	DataSignerCrc32 = func(data string) string {
+		n, _ := strconv.Atoi(data[1:])
+		time.Sleep(time.Duration(20-n) * time.Millisecond)
+		return data[1:]
+	}
+
+	result := []string{}
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			for i := 0; i < 20; i++ {
+				out <- strconv.Itoa(i)
+			}
+		}),
+		MultiHashOrdered(4),
+		job(func(in, out chan interface{}) {
+			for val := range in {
+				result = append(result, val.(string))
+			}
+		}),
+	)
+	expected := []string{}
+	for i := 0; i < 20; i++ {
+		expected = append(expected, strings.Repeat(strconv.Itoa(i), TH))
+	}
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+func TestSingleHashLenient(t *testing.T) {
+	origMd5, origCrc32 := DataSignerMd5, DataSignerCrc32
+	defer func() { DataSignerMd5, DataSignerCrc32 = origMd5, origCrc32 }()
+	DataSignerMd5 = func(data string) string { return "md5(" + data + ")" }
+	DataSignerCrc32 = func(data string) string { return "crc32(" + data + ")" }
+
+	// неподдерживаемое значение не должно обрывать поток, как было до пулов
+	input := []interface{}{1, 2.5, 3, 4, 5, 6, 7, 8, 9}
+	result := []string{}
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			for _, val := range input {
+				out <- val
+			}
+		}),
+		SingleHashOrdered(2),
+		job(func(in, out chan interface{}) {
+			for val := range in {
+				result = append(result, val.(string))
+			}
+		}),
+	)
+	if len(result) != len(input) {
+		t.Fatalf("results not match\nGot: %v\nExpected: %v results", result, len(input))
+	}
+	if expected := "crc32()~crc32(md5())"; result[1] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result[1], expected)
+	}
+}
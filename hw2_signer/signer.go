@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strconv"
@@ -10,80 +11,144 @@ import (
 
 const TH = 6
 
-func SingleHash(in chan interface{}, out chan interface{}) {
-	var data string
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-
-	for val := range in {
-		switch val.(type) {
-		case int:
-			data = strconv.Itoa(val.(int))
-		case string:
-			data = val.(string)
-		}
+// HashWorkers - сколько значений SingleHash и MultiHash считают одновременно
+const HashWorkers = 8
+
+// Hasher считает SingleHash и MultiHash подписями Digest и Checksum, так что
+// у каждого конвейера могут быть свои подписи без подмены глобальных DataSigner*
+type Hasher struct {
+	Digest   Signer // первый шаг SingleHash, в задании md5
+	Checksum Signer // остальные подписи, в задании crc32
+	Workers  int    // сколько значений считается одновременно, 0 - HashWorkers
+	Ordered  bool   // отдавать результаты в порядке входных значений
+
+	// lenient - как исходный SingleHash: значения не int и не string считаются пустой строкой
+	lenient bool
+}
 
-		wg.Add(1)
-		go AsyncSingleHash(data, out, &wg, &mutex)
+// dataHasher - Hasher на DataSignerMd5 и DataSignerCrc32 из common.go
+func dataHasher(workers int, ordered bool) Hasher {
+	return Hasher{
+		Digest:   DataMd5Signer,
+		Checksum: DataCrc32Signer,
+		Workers:  workers,
+		Ordered:  ordered,
+		lenient:  true,
 	}
-	wg.Wait()
 }
 
-func AsyncSingleHash(data string, out chan interface{}, wg *sync.WaitGroup, mutex *sync.Mutex) {
-	crc32Ch := make(chan string)
-	md5Ch := make(chan string)
+func SingleHash(in chan interface{}, out chan interface{}) {
+	SingleHashPool(HashWorkers)(in, out)
+}
 
-	go AsyncCrc32(data, crc32Ch)
-	go AsyncMD5(data, md5Ch, mutex)
+// SingleHashPool - SingleHash, который считает не больше workers значений одновременно,
+// пока все заняты, предыдущее звено ждёт на записи
+func SingleHashPool(workers int) job {
+	return withoutContext(dataHasher(workers, false).SingleHash())
+}
 
-	md5Hash := <-md5Ch
+// SingleHashOrdered - SingleHashPool, который отдаёт результаты в порядке входных значений
+func SingleHashOrdered(workers int) job {
+	return withoutContext(dataHasher(workers, true).SingleHash())
+}
 
-	go AsyncCrc32(md5Hash, md5Ch)
+// SingleHash - звено crc32(data)+"~"+crc32(md5(data)) на подписях Hasher
+func (h Hasher) SingleHash() ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		return runPool(ctx, h.Workers, h.Ordered, in, out, func(ctx context.Context, val interface{}) (interface{}, error) {
+			var data string
+			switch val := val.(type) {
+			case int:
+				data = strconv.Itoa(val)
+			case string:
+				data = val
+			default:
+				if !h.lenient {
+					return nil, fmt.Errorf("SingleHash: unsupported value %T, expected int or string", val)
+				}
+			}
+			return h.singleHash(ctx, data)
+		})
+	}
+}
 
-	crc32Hash := <-crc32Ch
-	crc32md5Hash := <-md5Ch
+type signResult struct {
+	hash string
+	err  error
+}
 
-	out <- crc32Hash + "~" + crc32md5Hash
+func (h Hasher) singleHash(ctx context.Context, data string) (string, error) {
+	// канал с буфером, чтобы горутина не зависла, если мы выйдем по ошибке
+	crc32Ch := make(chan signResult, 1)
+	go func() {
+		hash, err := h.Checksum.Sign(ctx, data)
+		crc32Ch <- signResult{hash, err}
+	}()
+
+	md5Hash, err := h.Digest.Sign(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	crc32md5Hash, err := h.Checksum.Sign(ctx, md5Hash)
+	if err != nil {
+		return "", err
+	}
+	crc32Hash := <-crc32Ch
+	if crc32Hash.err != nil {
+		return "", crc32Hash.err
+	}
 
-	wg.Done()
+	return crc32Hash.hash + "~" + crc32md5Hash, nil
 }
 
-func AsyncCrc32(data string, res chan<- string) {
-	res <- DataSignerCrc32(data)
+func MultiHash(in chan interface{}, out chan interface{}) {
+	MultiHashPool(HashWorkers)(in, out)
 }
 
-func AsyncMD5(data string, res chan<- string, mutex *sync.Mutex) {
-	mutex.Lock()
-	res <- DataSignerMd5(data)
-	mutex.Unlock()
+// MultiHashPool - MultiHash не больше чем на workers значениях одновременно,
+// то есть не больше workers*TH вызовов DataSignerCrc32
+func MultiHashPool(workers int) job {
+	return withoutContext(dataHasher(workers, false).MultiHash())
 }
 
-func MultiHash(in chan interface{}, out chan interface{}) {
-	var wg sync.WaitGroup
+// MultiHashOrdered - MultiHashPool, который отдаёт результаты в порядке входных значений
+func MultiHashOrdered(workers int) job {
+	return withoutContext(dataHasher(workers, true).MultiHash())
+}
 
-	for shash := range in {
-		wg.Add(1)
-		go AsyncMultiHash(shash.(string), out, &wg)
+// MultiHash - звено, склеивающее TH подписей th+data на подписях Hasher
+func (h Hasher) MultiHash() ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		return runPool(ctx, h.Workers, h.Ordered, in, out, func(ctx context.Context, shash interface{}) (interface{}, error) {
+			data, ok := shash.(string)
+			if !ok {
+				return nil, fmt.Errorf("MultiHash: unsupported value %T, expected string", shash)
+			}
+			return h.multiHash(ctx, data)
+		})
 	}
-	wg.Wait()
 }
 
-func AsyncMultiHash(data string, out chan interface{}, wg *sync.WaitGroup) {
+func (h Hasher) multiHash(ctx context.Context, data string) (string, error) {
 	var wgl sync.WaitGroup
 	multiHash := make([]string, TH)
+	errs := make([]error, TH)
 
 	for i := 0; i < TH; i++ {
 		wgl.Add(1)
 		go func(data string, th int) {
-			multiHash[th] = DataSignerCrc32(strconv.Itoa(th) + data)
+			multiHash[th], errs[th] = h.Checksum.Sign(ctx, strconv.Itoa(th)+data)
 			wgl.Done()
 		}(data, i)
 	}
 	wgl.Wait()
 
-	out <- strings.Join(multiHash, "")
-
-	wg.Done()
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(multiHash, ""), nil
 }
 
 func CombineResults(in chan interface{}, out chan interface{}) {
@@ -101,23 +166,11 @@ func CombineResults(in chan interface{}, out chan interface{}) {
 }
 
 func ExecutePipeline(jobs ...job) {
-	var inCh = make(chan interface{})
-	var outCh = make(chan interface{})
-	var wg sync.WaitGroup
-
+	ctxJobs := make([]ctxJob, 0, len(jobs))
 	for _, j := range jobs {
-		wg.Add(1)
-
-		go func(task job, in, out chan interface{}) {
-			task(in, out)
-			close(out)
-			wg.Done()
-		}(j, inCh, outCh)
-
-		inCh = outCh
-		outCh = make(chan interface{})
+		ctxJobs = append(ctxJobs, withContext(j))
 	}
-	wg.Wait()
+	ExecutePipelineContext(context.Background(), ctxJobs...)
 }
 
 func main() {
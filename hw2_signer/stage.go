@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stage - типизированное звено конвейера: читает In из in и пишет Out в out
+type Stage[In, Out any] func(ctx context.Context, in <-chan In, out chan<- Out) error
+
+var (
+	SingleHashStage     = FromJob[int, string](SingleHash)
+	MultiHashStage      = FromJob[string, string](MultiHash)
+	CombineResultsStage = FromJob[string, string](CombineResults)
+
+	// звенья, которые сохраняют порядок значений, результат i соответствует входу i
+	SingleHashOrderedStage = FromJob[int, string](SingleHashOrdered(HashWorkers))
+	MultiHashOrderedStage  = FromJob[string, string](MultiHashOrdered(HashWorkers))
+)
+
+// Chain соединяет два звена. Ошибка любого из них отменяет ctx другого,
+// завершение second останавливает first
+func Chain[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return func(ctx context.Context, in <-chan A, out chan<- C) error {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		mid := make(chan B)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if err := first(ctx, in, mid); err != nil {
+				cancel(err)
+			}
+			close(mid)
+		}()
+
+		err := second(ctx, mid, out)
+		if err == nil {
+			err = errPipelineDone
+		}
+		cancel(err)
+		for range mid {
+		}
+		<-done
+
+		if err := context.Cause(ctx); err != errPipelineDone {
+			return err
+		}
+		return nil
+	}
+}
+
+// FromJob превращает обычный job в типизированное звено. Значение не того типа
+// на выходе job становится ошибкой, а не паникой
+func FromJob[In, Out any](j job) Stage[In, Out] {
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		// отмена feedCtx перестаёт подавать job новые значения
+		feedCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		jobIn, jobOut := make(chan interface{}), make(chan interface{})
+		go func() {
+			defer close(jobIn)
+			for val := range in {
+				select {
+				case jobIn <- val:
+				case <-feedCtx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			j(jobIn, jobOut)
+			close(jobOut)
+		}()
+
+		// job ничего не знает про ctx, поэтому после ошибки дочитываем его выход до конца
+		var err error
+		for val := range jobOut {
+			if err != nil {
+				continue
+			}
+			typed, ok := val.(Out)
+			if !ok {
+				err = fmt.Errorf("job returned %T, expected %T", val, typed)
+				cancel()
+				continue
+			}
+			select {
+			case out <- typed:
+			case <-ctx.Done():
+				err = ctx.Err()
+				cancel()
+			}
+		}
+		return err
+	}
+}
+
+// Run прогоняет values через stage и собирает всё, что он выдал
+func Run[In, Out any](ctx context.Context, stage Stage[In, Out], values ...In) ([]Out, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in, out := make(chan In), make(chan Out)
+	go func() {
+		defer close(in)
+		for _, val := range values {
+			select {
+			case in <- val:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- stage(ctx, in, out)
+		close(out)
+	}()
+
+	results := []Out{}
+	for val := range out {
+		results = append(results, val)
+	}
+	return results, <-errc
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStageSigner(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+
+	signer := Chain(Chain(SingleHashStage, MultiHashStage), CombineResultsStage)
+	start := time.Now()
+	result, err := Run(context.Background(), signer, 0, 1)
+	end := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0] != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, testExpected)
+	}
+	if expectedTime := 3 * time.Second; end > expectedTime {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, expectedTime)
+	}
+}
+
+func TestStageTypeError(t *testing.T) {
+	// job отдаёт строку там, где звено обещало int
+	bad := FromJob[int, int](func(in, out chan interface{}) {
+		for val := range in {
+			out <- strconv.Itoa(val.(int))
+		}
+	})
+	// бесконечный вход: звено вернётся, только если после ошибки перестанет его читать
+	in, stop := make(chan int), make(chan struct{})
+	defer close(stop)
+	var forwarded int32
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+				atomic.AddInt32(&forwarded, 1)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- bad(context.Background(), in, make(chan int))
+	}()
+
+	select {
+	case err := <-errc:
+		if err == nil || !strings.Contains(err.Error(), "job returned string, expected int") {
+			t.Errorf("expected type error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("stage kept reading after type error, forwarded %v values", atomic.LoadInt32(&forwarded))
+	}
+}
+
+func TestStageChainError(t *testing.T) {
+	errBad := errors.New("bad value")
+	double := Stage[int, int](func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			select {
+			case out <- val * 2:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	check := Stage[int, string](func(ctx context.Context, in <-chan int, out chan<- string) error {
+		for val := range in {
+			if val > 4 {
+				return errBad
+			}
+			out <- strconv.Itoa(val)
+		}
+		return nil
+	})
+
+	result, err := Run(context.Background(), Chain(double, check), 1, 2, 3, 4)
+	if !errors.Is(err, errBad) {
+		t.Errorf("expected %v, got %v", errBad, err)
+	}
+	if strings.Join(result, ",") != "2,4" {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, "[2 4]")
+	}
+}
+
+func TestStageOrdered(t *testing.T) {
+	// результаты без сортировки, по позициям входных значений
+	testExpected := []string{
+		"4958044192186797981418233587017209679042592862002427381542",
+		"29568666068035183841425683795340791879727309630931025356555",
+	}
+
+	signer := Chain(SingleHashOrderedStage, MultiHashOrderedStage)
+	start := time.Now()
+	result, err := Run(context.Background(), signer, 1, 0)
+	end := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(result, "_") != strings.Join(testExpected, "_") {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, testExpected)
+	}
+	if expectedTime := 3 * time.Second; end > expectedTime {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, expectedTime)
+	}
+}